@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/google/go-querystring/query"
+)
+
+// actorLoginTTL is how long a resolved login is trusted before its actor ID is looked up again
+const actorLoginTTL = 24 * time.Hour
+
+// actorLogins resolves actor IDs to their current logins with --resolve-actor-ids, or is nil
+var actorLogins *actorResolver
+
+// rawAuditEntry is an audit entry as GitHub returns it, keeping the actor ID that go-github's AuditEntry drops
+type rawAuditEntry struct {
+	*github.AuditEntry
+	ActorID int64 `json:"actor_id,omitempty"`
+}
+
+// actorLogin is the login an actor ID was last seen to have
+type actorLogin struct {
+	Login   string    `json:"login"`
+	Checked time.Time `json:"checked"`
+}
+
+// actorResolver replaces audit entries' actors with the current logins of their actor IDs, so that renamed
+// accounts are tracked as one by the detectors and state files. Logins are cached in path for actorLoginTTL.
+type actorResolver struct {
+	c    *github.Client
+	path string
+
+	Logins map[int64]actorLogin `json:"logins"`
+}
+
+// loadActorResolver reads the cached logins from path
+func loadActorResolver(c *github.Client, path string) (*actorResolver, error) {
+	r := &actorResolver{c: c, path: path, Logins: map[int64]actorLogin{}}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if r.Logins == nil {
+		r.Logins = map[int64]actorLogin{}
+	}
+	return r, nil
+}
+
+// resolve replaces a's actor with the current login for id. Entries without an actor ID, or whose ID cannot
+// be looked up, such as deleted accounts, keep their login.
+func (r *actorResolver) resolve(ctx context.Context, a *github.AuditEntry, id int64) {
+	if id == 0 {
+		return
+	}
+
+	l, ok := r.Logins[id]
+	if !ok || time.Since(l.Checked) > actorLoginTTL {
+		u, _, err := r.c.Users.GetByID(ctx, id)
+		switch {
+		case err != nil:
+			log.Printf("unable to resolve actor ID %d of %s: %v", id, a.GetActor(), err)
+		case u.GetLogin() != "":
+			if u.GetLogin() != a.GetActor() {
+				log.Printf("actor %s has been renamed to %s", a.GetActor(), u.GetLogin())
+			}
+			l = actorLogin{Login: u.GetLogin(), Checked: time.Now()}
+			r.Logins[id] = l
+		}
+	}
+
+	if l.Login != "" {
+		a.Actor = github.String(l.Login)
+	}
+}
+
+func (r *actorResolver) save() error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.path, b)
+}
+
+// getAuditLog is Organizations.GetAuditLog, except that with --resolve-actor-ids the entries' actors are
+// resolved to their current logins
+func getAuditLog(ctx context.Context, c *github.Client, org string, opts *github.GetAuditLogOptions) ([]*github.AuditEntry, *github.Response, error) {
+	if actorLogins == nil {
+		return c.Organizations.GetAuditLog(ctx, org, opts)
+	}
+
+	v, err := query.Values(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := c.NewRequest("GET", fmt.Sprintf("orgs/%s/audit-log?%s", org, v.Encode()), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw := []*rawAuditEntry{}
+	resp, err := c.Do(ctx, req, &raw)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	as := []*github.AuditEntry{}
+	for _, e := range raw {
+		if e.AuditEntry == nil {
+			e.AuditEntry = &github.AuditEntry{}
+		}
+		actorLogins.resolve(ctx, e.AuditEntry, e.ActorID)
+		as = append(as, e.AuditEntry)
+	}
+	return as, resp, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+func TestResolveActorIDs(t *testing.T) {
+	*orgFlag = testOrg
+	lookups := map[string]int{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/"+testOrg+"/audit-log", func(w http.ResponseWriter, r *http.Request) {
+		at := ago(time.Minute).Format(time.RFC3339)
+		fmt.Fprintf(w, `[
+			{"action": "repo.destroy", "actor": "alice", "actor_id": 1, "@timestamp": %q},
+			{"action": "repo.create", "actor": "alice", "actor_id": 1, "@timestamp": %q},
+			{"action": "repo.destroy", "actor": "bob", "@timestamp": %q},
+			{"action": "repo.destroy", "actor": "carol", "actor_id": 3, "@timestamp": %q}
+		]`, at, at, at, at)
+	})
+	mux.HandleFunc("/user/{id}", func(w http.ResponseWriter, r *http.Request) {
+		lookups[r.PathValue("id")]++
+		if r.PathValue("id") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"login": "alice-renamed", "id": 1}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	c := github.NewClient(nil)
+	c.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	path := filepath.Join(t.TempDir(), "actor-logins.json")
+	var err error
+	actorLogins, err = loadActorResolver(c, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { actorLogins = nil }()
+
+	as, err := fetchAuditLog(context.Background(), c, "web", "", ago(time.Hour), "desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entries without an actor ID, or whose ID is not found, keep their login
+	want := []string{"alice-renamed", "bob", "carol"}
+	if got := actors(as); !equalStrings(got, want) {
+		t.Errorf("actors = %q, want %q", got, want)
+	}
+	if lookups["1"] != 1 || lookups["3"] != 1 || len(lookups) != 2 {
+		t.Errorf("lookups = %v, want one per actor ID", lookups)
+	}
+
+	// Resolved logins are cached between runs
+	if err := actorLogins.save(); err != nil {
+		t.Fatal(err)
+	}
+	actorLogins, err = loadActorResolver(c, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetchAuditLog(context.Background(), c, "web", "", ago(time.Hour), "desc"); err != nil {
+		t.Fatal(err)
+	}
+	if lookups["1"] != 1 {
+		t.Errorf("actor ID 1 looked up %d times, want it cached", lookups["1"])
+	}
+
+	// Stale logins are looked up again
+	actorLogins.Logins[1] = actorLogin{Login: "alice-renamed", Checked: ago(2 * actorLoginTTL)}
+	b, _ := json.Marshal(actorLogins)
+	if err := writeFileAtomic(path, b); err != nil {
+		t.Fatal(err)
+	}
+	actorLogins, _ = loadActorResolver(c, path)
+	if _, err := fetchAuditLog(context.Background(), c, "web", "", ago(time.Hour), "desc"); err != nil {
+		t.Fatal(err)
+	}
+	if lookups["1"] != 2 {
+		t.Errorf("stale actor ID 1 looked up %d times, want 2", lookups["1"])
+	}
+}
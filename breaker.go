@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// maxSummaryLines is how many held back alerts are listed in a flood summary
+const maxSummaryLines = 20
+
+var errCircuitOpen = errors.New("circuit open, alert dropped")
+
+// errHeld is returned for alerts held back for a flood summary, which are neither delivered nor failed
+var errHeld = errors.New("held for a flood summary")
+
+// breaker wraps a notifier, dropping alerts for a cooldown after repeated failures,
+// and holding alerts back for a single summary once too many are sent in a short window.
+type breaker struct {
+	n notifier
+
+	// maxFailures consecutive failures open the circuit for cooldown; zero disables
+	maxFailures int
+	cooldown    time.Duration
+	// maxAlerts sent within window switches to summarized posting; zero disables
+	maxAlerts int
+	window    time.Duration
+
+	failures  int
+	openUntil time.Time
+	sent      []time.Time
+	held      []string
+}
+
+func (b *breaker) Notify(ctx context.Context, al *alert) error {
+	now := time.Now()
+	if now.Before(b.openUntil) {
+		log.Printf("[circuit open, dropped] %s", al)
+		return errCircuitOpen
+	}
+
+	recent := []time.Time{}
+	for _, t := range b.sent {
+		if now.Sub(t) < b.window {
+			recent = append(recent, t)
+		}
+	}
+	b.sent = recent
+
+	if b.maxAlerts > 0 && len(b.sent) >= b.maxAlerts {
+		log.Printf("[held for summary] %s", al)
+		b.held = append(b.held, al.String())
+		return errHeld
+	}
+
+	if err := b.n.Notify(ctx, al); err != nil {
+		b.failures++
+		if b.maxFailures > 0 && b.failures >= b.maxFailures {
+			b.openUntil = now.Add(b.cooldown)
+			b.failures = 0
+			log.Printf("circuit opened after %d consecutive failures, dropping alerts until %s", b.maxFailures, b.openUntil)
+		}
+		return err
+	}
+
+	b.failures = 0
+	b.sent = append(b.sent, now)
+	return nil
+}
+
+// Flush posts a single summary of the alerts held back during a flood
+func (b *breaker) Flush(ctx context.Context) error {
+	if len(b.held) == 0 {
+		return nil
+	}
+
+	lines := b.held
+	if len(lines) > maxSummaryLines {
+		lines = lines[:maxSummaryLines]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d alerts were summarized after %d alerts within %s:\n", len(b.held), b.maxAlerts, b.window))
+	sb.WriteString(strings.Join(lines, "\n"))
+	if len(b.held) > len(lines) {
+		sb.WriteString(fmt.Sprintf("\n(+%d more)", len(b.held)-len(lines)))
+	}
+
+	b.held = nil
+	return b.n.Notify(ctx, newAlert(nil, "", sb.String(), repoSet{}))
+}
+
+// flusher is implemented by notifiers that hold alerts back until the end of a run
+type flusher interface {
+	Flush(ctx context.Context) error
+}
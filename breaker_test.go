@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBreakerCircuit(t *testing.T) {
+	down := true
+	n := &fakeNotifier{fail: func(*alert) bool { return down }}
+	b := &breaker{n: n, maxFailures: 2, cooldown: time.Hour}
+	ctx := context.Background()
+
+	for i, want := range []error{nil, nil, errCircuitOpen, errCircuitOpen} {
+		err := b.Notify(ctx, newAlert(nil, "", fmt.Sprintf("alert %d", i), repoSet{}))
+		if err == nil {
+			t.Fatalf("alert %d: got no error", i)
+		}
+		if want != nil && !errors.Is(err, want) {
+			t.Errorf("alert %d: error = %v, want %v", i, err, want)
+		}
+		if want == nil && errors.Is(err, errCircuitOpen) {
+			t.Errorf("alert %d: circuit open before %d failures", i, b.maxFailures)
+		}
+	}
+
+	// Once the cooldown has passed, alerts are sent again
+	down = false
+	b.openUntil = time.Now().Add(-time.Second)
+	if err := b.Notify(ctx, newAlert(nil, "", "recovered", repoSet{})); err != nil {
+		t.Fatalf("after cooldown: %v", err)
+	}
+	if len(n.sent) != 1 || n.sent[0].Message != "recovered" {
+		t.Errorf("sent = %v, want only the alert after the cooldown", n.sent)
+	}
+}
+
+func TestBreakerFlood(t *testing.T) {
+	n := &fakeNotifier{}
+	b := &breaker{n: n, maxAlerts: 2, window: time.Hour}
+	ctx := context.Background()
+
+	for i := range 2 + maxSummaryLines + 3 {
+		err := b.Notify(ctx, newAlert(nil, "", fmt.Sprintf("alert %d", i), repoSet{}))
+		switch {
+		case i < 2 && err != nil:
+			t.Errorf("alert %d: %v", i, err)
+		case i >= 2 && !errors.Is(err, errHeld):
+			t.Errorf("alert %d: error = %v, want %v", i, err, errHeld)
+		}
+	}
+	if len(n.sent) != 2 {
+		t.Fatalf("sent %d alerts before the summary, want 2", len(n.sent))
+	}
+
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if len(n.sent) != 3 {
+		t.Fatalf("sent %d alerts, want 2 and a summary", len(n.sent))
+	}
+	summary := n.sent[2].Message
+	for _, want := range []string{
+		fmt.Sprintf("%d alerts were summarized after 2 alerts within 1h0m0s:\n", maxSummaryLines+3),
+		"\nalert 2\n",
+		fmt.Sprintf("\nalert %d\n", maxSummaryLines+1),
+		"\n(+3 more)",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q does not contain %q", summary, want)
+		}
+	}
+	if strings.Contains(summary, fmt.Sprintf("alert %d\n", maxSummaryLines+2)) {
+		t.Errorf("summary %q lists more than %d alerts", summary, maxSummaryLines)
+	}
+
+	// Nothing is held any more, so a second flush sends nothing
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("second Flush: %v", err)
+	}
+	if len(n.sent) != 3 {
+		t.Errorf("second flush sent %d alerts, want none", len(n.sent)-3)
+	}
+}
+
+func TestRunStatsHeld(t *testing.T) {
+	ok := &fakeNotifier{}
+	flood := &breaker{n: &fakeNotifier{}, maxAlerts: 1, window: time.Hour}
+	stats := &runStats{}
+	for _, m := range []string{"one", "two", "three"} {
+		stats.notify(context.Background(), []notifier{ok, flood}, newAlert(nil, "", m, repoSet{}))
+	}
+	want := "0 events scanned, 3 alerts attempted, 1 delivered, 0 delivery failures, 2 held for flood summaries"
+	if got := stats.String(); got != want {
+		t.Errorf("stats = %q, want %q", got, want)
+	}
+	if stats.lastErr != nil {
+		t.Errorf("last error = %v, want none", stats.lastErr)
+	}
+}
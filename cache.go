@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// auditCache is the on-disk form of previously fetched audit log entries
+type auditCache struct {
+	// Since is how far back Entries are complete
+	Since time.Time `json:"since"`
+	// Entries are ordered newest first
+	Entries []*github.AuditEntry `json:"entries"`
+}
+
+func loadAuditCache(path string) (*auditCache, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ac := &auditCache{}
+	if err := json.Unmarshal(b, ac); err != nil {
+		return nil, err
+	}
+	return ac, nil
+}
+
+func saveAuditCache(path string, ac *auditCache) error {
+	b, err := json.Marshal(ac)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, b)
+}
+
+// cachedAuditLog returns audit entries back to since, only querying GitHub for entries newer than the cache
+func cachedAuditLog(ctx context.Context, c *github.Client, kind string, since time.Time) ([]*github.AuditEntry, error) {
+	path := filepath.Join(*cacheDirFlag, fmt.Sprintf("%s-%s.json", *orgFlag, kind))
+
+	ac, err := loadAuditCache(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("ignoring unreadable cache %s: %v", path, err)
+		}
+		ac = &auditCache{}
+	}
+
+	// The cache is only usable if it reaches back far enough to cover the requested window
+	stop := since
+	if len(ac.Entries) > 0 && !ac.Since.After(since) {
+		stop = ac.Entries[0].GetTimestamp().Time
+		log.Printf("%d %q entries cached back to %s, fetching newer than %s", len(ac.Entries), kind, ac.Since, stop)
+	} else {
+		ac = &auditCache{Since: since}
+	}
+
+	fetched, err := fetchAuditLog(ctx, c, kind, "", stop, "desc")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	merged := []*github.AuditEntry{}
+	for _, e := range append(fetched, ac.Entries...) {
+		fp := fingerprint(e)
+		if seen[fp] {
+			continue
+		}
+		seen[fp] = true
+		merged = append(merged, e)
+	}
+
+	oldest := time.Now().Add(-*cacheMaxAgeFlag)
+	if oldest.After(ac.Since) {
+		ac.Since = oldest
+	}
+	ac.Entries = []*github.AuditEntry{}
+	for _, e := range merged {
+		if e.GetTimestamp().Before(ac.Since) {
+			break
+		}
+		ac.Entries = append(ac.Entries, e)
+	}
+	if len(ac.Entries) > *cacheMaxEntriesFlag {
+		ac.Entries = ac.Entries[:*cacheMaxEntriesFlag]
+		ac.Since = ac.Entries[len(ac.Entries)-1].GetTimestamp().Time
+	}
+
+	if err := saveAuditCache(path, ac); err != nil {
+		log.Printf("unable to save cache %s: %v", path, err)
+	}
+
+	as := []*github.AuditEntry{}
+	for _, e := range merged {
+		if e.GetTimestamp().Before(since) {
+			break
+		}
+		as = append(as, e)
+	}
+	return as, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// unknownCategory is the category of alerts whose action matches no category, and of alerts without an entry
+const unknownCategory = "unknown"
+
+// actionCategory groups actions matching re under a normalized name, such as "secrets", for SIEM rules
+type actionCategory struct {
+	re   *regexp.Regexp
+	name string
+}
+
+// defaultCategories are the action-regexp=category pairs checked after any --category; the first match wins
+var defaultCategories = []string{
+	`.*secret.*=secrets`,
+	`personal_access_token.*=secrets`,
+	`public_key.*=secrets`,
+	`repo.(add|remove)_deploy_key=secrets`,
+	`repo.access=visibility`,
+	`.*visibility.*=visibility`,
+	`org.(add|remove|invite|update)_.*member.*=membership`,
+	`org.(add|remove)_outside_collaborator=membership`,
+	`repo.(add|remove|update)_member=membership`,
+	`team.*=membership`,
+	`git.clone=access`,
+	`.*permission.*=access`,
+	`protected_branch.*=access`,
+	`oauth_application.*=access`,
+	`integration_installation.*=access`,
+	`org.disable_two_factor_requirement=access`,
+}
+
+// parseCategories parses "action-regexp=category" pairs, matching actions in full and ignoring case like the
+// ignore lists, followed by defaultCategories
+func parseCategories(pairs []string) ([]actionCategory, error) {
+	cs := []actionCategory{}
+	for _, p := range append(append([]string{}, pairs...), defaultCategories...) {
+		pattern, name, ok := strings.Cut(p, "=")
+		if !ok || pattern == "" || name == "" {
+			return nil, fmt.Errorf("%q is not in action-regexp=category form", p)
+		}
+		re, err := regexp.Compile(fmt.Sprintf("(?i)^(?:%s)$", pattern))
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, actionCategory{re: re, name: name})
+	}
+	return cs, nil
+}
+
+// categoryFor returns the category of the first of cs matching an entry's action, or unknownCategory
+func categoryFor(a *github.AuditEntry, cs []actionCategory) string {
+	if a == nil {
+		return unknownCategory
+	}
+	for _, c := range cs {
+		if c.re.MatchString(a.GetAction()) {
+			return c.name
+		}
+	}
+	return unknownCategory
+}
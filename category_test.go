@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCategoryFor(t *testing.T) {
+	cs, err := parseCategories([]string{`repo\.destroy=destruction`, `team.*=teams`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for action, want := range map[string]string{
+		"repo.destroy":                   "destruction",
+		"team.add_member":                "teams",
+		"org.update_actions_secret":      "secrets",
+		"Personal_Access_Token.Request":  "secrets",
+		"repo.access":                    "visibility",
+		"org.add_member":                 "membership",
+		"git.clone":                      "access",
+		"repo.update_actions_permission": "access",
+		"repo.create":                    unknownCategory,
+	} {
+		a := entry(action, "alice", "widgets", ago(time.Minute))
+		if got := categoryFor(a, cs); got != want {
+			t.Errorf("categoryFor(%s) = %q, want %q", action, got, want)
+		}
+	}
+	if got := categoryFor(nil, cs); got != unknownCategory {
+		t.Errorf("categoryFor(nil) = %q, want %q", got, unknownCategory)
+	}
+
+	for _, bad := range []string{"repo.destroy", "=destruction", "repo.destroy=", "repo.(destroy=destruction"} {
+		if _, err := parseCategories([]string{bad}); err == nil {
+			t.Errorf("parseCategories(%q) did not fail", bad)
+		}
+	}
+}
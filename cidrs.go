@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxCIDRListBytes bounds the size of a fetched trusted network list
+const maxCIDRListBytes = 1 << 20
+
+// cidrClient fetches trusted network lists, giving up on an unresponsive endpoint so that the cached list is used
+var cidrClient = &http.Client{Timeout: 30 * time.Second}
+
+// parseCIDRList parses a published list of CIDRs or IPs, separated by commas or whitespace, with # comments
+func parseCIDRList(b []byte) ([]netip.Prefix, error) {
+	items := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		items = append(items, strings.Fields(line)...)
+	}
+	return parseCIDRs(strings.Join(items, ","))
+}
+
+// trustedCIDRs returns the trusted networks published at url, fetching them at most every refresh and
+// keeping the last good list in cachePath. If a fetch fails, the cached list is used, however old.
+func trustedCIDRs(ctx context.Context, url string, cachePath string, refresh time.Duration) ([]netip.Prefix, error) {
+	if fi, err := os.Stat(cachePath); err == nil && time.Since(fi.ModTime()) < refresh {
+		b, err := os.ReadFile(cachePath)
+		if err == nil {
+			return parseCIDRList(b)
+		}
+	}
+
+	prefixes, b, err := fetchCIDRs(ctx, url)
+	if err == nil {
+		log.Printf("refreshed %d trusted networks from %s", len(prefixes), url)
+		if err := saveCIDRCache(cachePath, b); err != nil {
+			log.Printf("unable to cache trusted networks: %v", err)
+		}
+		return prefixes, nil
+	}
+
+	cached, cerr := os.ReadFile(cachePath)
+	if cerr != nil {
+		return nil, fmt.Errorf("%w, and no cached list: %w", err, cerr)
+	}
+	log.Printf("unable to refresh trusted networks, using the last good list: %v", err)
+	return parseCIDRList(cached)
+}
+
+// fetchCIDRs fetches and parses a trusted network list, returning the body for caching
+func fetchCIDRs(ctx context.Context, url string) ([]netip.Prefix, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := cidrClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxCIDRListBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+	prefixes, err := parseCIDRList(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse %s: %w", url, err)
+	}
+	return prefixes, b, nil
+}
+
+func saveCIDRCache(path string, b []byte) error {
+	return writeFileAtomic(path, b)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// cloneBurst is a user's clone burst that has been alerted on
+type cloneBurst struct {
+	Actor string    `json:"actor"`
+	Start time.Time `json:"start"`
+	// Repos is the number of distinct repos cloned when last alerted on
+	Repos int `json:"repos"`
+}
+
+// cloneBursts records the clone bursts alerted on by earlier runs, so that overlapping
+// alert windows do not alert on the same burst again unless it grows
+type cloneBursts struct {
+	path string
+
+	Bursts []*cloneBurst `json:"bursts"`
+}
+
+// loadCloneBursts reads the bursts alerted on so far from path
+func loadCloneBursts(path string) (*cloneBursts, error) {
+	cb := &cloneBursts{path: path, Bursts: []*cloneBurst{}}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cb, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cb); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cb, nil
+}
+
+func (cb *cloneBursts) find(actor string, start time.Time) *cloneBurst {
+	for _, b := range cb.Bursts {
+		if b.Actor == actor && b.Start.Equal(start) {
+			return b
+		}
+	}
+	return nil
+}
+
+// alerted reports whether the burst by actor that started at start was already alerted on with at least repos repos
+func (cb *cloneBursts) alerted(actor string, start time.Time, repos int) bool {
+	if cb == nil {
+		return false
+	}
+	b := cb.find(actor, start)
+	return b != nil && repos <= b.Repos
+}
+
+// record notes that the burst by actor that started at start was alerted on with repos repos
+func (cb *cloneBursts) record(actor string, start time.Time, repos int) {
+	if cb == nil {
+		return
+	}
+	if b := cb.find(actor, start); b != nil {
+		b.Repos = repos
+		return
+	}
+	cb.Bursts = append(cb.Bursts, &cloneBurst{Actor: actor, Start: start, Repos: repos})
+}
+
+// save writes the bursts, dropping those that started before since, as their first clone has aged out of the search
+func (cb *cloneBursts) save(since time.Time) error {
+	kept := []*cloneBurst{}
+	for _, b := range cb.Bursts {
+		if !b.Start.Before(since) {
+			kept = append(kept, b)
+		}
+	}
+	cb.Bursts = kept
+
+	b, err := json.MarshalIndent(cb, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cb.path, b)
+}
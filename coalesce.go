@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// coalescedKind is the kind of an alert merging an actor's web event and clone alerts
+const coalescedKind = "web and clone activity"
+
+// coalesceActors merges the alerts of each actor who tripped both a web event detector and the clone
+// detector into one alert, in place of the actor's first. The merged alert takes the first alert's entry
+// and the highest severity among them, and mentions the channel if any of them did. Other alerts are
+// returned unchanged, in order, as are always-alert entries, so that they keep their mention and are never summarized.
+func coalesceActors(alerts []*alert) []*alert {
+	web := map[string]bool{}
+	clones := map[string]bool{}
+	groups := map[string][]*alert{}
+	for _, al := range alerts {
+		if al.Entry == nil || alwaysAlert(al.Entry) {
+			continue
+		}
+		actor := actorName(al.Entry)
+		if al.Entry.GetAction() == "git.clone" {
+			clones[actor] = true
+		} else {
+			web[actor] = true
+		}
+		groups[actor] = append(groups[actor], al)
+	}
+
+	out := []*alert{}
+	merged := map[string]bool{}
+	for _, al := range alerts {
+		if al.Entry == nil || alwaysAlert(al.Entry) {
+			out = append(out, al)
+			continue
+		}
+		actor := actorName(al.Entry)
+		if !web[actor] || !clones[actor] {
+			out = append(out, al)
+			continue
+		}
+		if merged[actor] {
+			continue
+		}
+		merged[actor] = true
+
+		group := groups[actor]
+		lines := []string{}
+		sev := group[0].Severity
+		mention := false
+		for _, g := range group {
+			lines = append(lines, g.String())
+			if severityRank(g.Severity) > severityRank(sev) {
+				sev = g.Severity
+			}
+			mention = mention || g.Mention
+		}
+		out = append(out, &alert{
+			Entry:    group[0].Entry,
+			Kind:     coalescedKind,
+			Message:  fmt.Sprintf("%s tripped both web event and clone detectors, with %d alerts:\n%s", actor, len(group), strings.Join(lines, "\n")),
+			Severity: sev,
+			Mention:  mention,
+		})
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+func TestCoalesceActors(t *testing.T) {
+	critical, err := normalizeRepos(testOrg, []string{"vault"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := func(action string, repo string, at time.Time) *github.AuditEntry {
+		a := entry(action, "", repo, at)
+		a.Actor = nil
+		return a
+	}
+	alerts := []*alert{
+		newAlert(entry("repo.destroy", "alice", "widgets", ago(50*time.Minute)), "", "alice destroyed widgets", critical),
+		newAlert(entry("repo.destroy", "bob", "gadgets", ago(45*time.Minute)), "", "bob destroyed gadgets", critical),
+		newAlert(nil, "", "an error", critical),
+		newAlert(clone("alice", "vault", ago(40*time.Minute)), "excessive clone[>=3]", "alice cloned vault", critical),
+		// Only clones
+		newAlert(clone("carol", "one", ago(35*time.Minute)), "excessive clone[>=3]", "carol cloned one", critical),
+		// Deleted users are one actor
+		newAlert(deleted("repo.destroy", "old", ago(30*time.Minute)), "", "someone destroyed old", critical),
+		newAlert(deleted("git.clone", "old", ago(25*time.Minute)), "excessive clone[>=3]", "someone cloned old", critical),
+		// Always alerted on separately, keeping its mention
+		newAlert(entry("org.disable_two_factor_requirement", "alice", "", ago(20*time.Minute)), "", "alice disabled 2FA", critical),
+		newAlert(entry("repo.add_member", "alice", "widgets", ago(10*time.Minute)), "", "alice added a member", critical),
+	}
+
+	got := coalesceActors(alerts)
+	want := []string{
+		coalescedKind + ": alice",
+		"bob destroyed gadgets",
+		"an error",
+		"carol cloned one",
+		coalescedKind + ": <deleted-user>",
+		"alice disabled 2FA",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("coalesceActors returned %d alerts, want %d: %v", len(got), len(want), got)
+	}
+	for i, al := range got {
+		if al.Kind == coalescedKind {
+			if w := coalescedKind + ": " + actorName(al.Entry); w != want[i] {
+				t.Errorf("alert %d merged %s, want %s", i, w, want[i])
+			}
+			continue
+		}
+		if al.Message != want[i] {
+			t.Errorf("alert %d = %q, want %q", i, al.Message, want[i])
+		}
+	}
+
+	merged := got[0]
+	if merged.Entry != alerts[0].Entry {
+		t.Error("merged alert does not keep the actor's first entry")
+	}
+	if !strings.HasPrefix(merged.Message, "alice tripped both web event and clone detectors, with 3 alerts:") {
+		t.Errorf("merged message = %q", merged.Message)
+	}
+	for _, m := range []string{"alice destroyed widgets", "alice cloned vault", "alice added a member"} {
+		if !strings.Contains(merged.Message, m) {
+			t.Errorf("merged message is missing %q: %s", m, merged.Message)
+		}
+	}
+	if strings.Contains(merged.Message, "2FA") {
+		t.Errorf("always-alert entry was merged: %s", merged.Message)
+	}
+	// The clone of a critical repo is the most severe
+	if merged.Severity != severityCritical {
+		t.Errorf("merged severity = %s, want critical", merged.Severity)
+	}
+	if strings.HasPrefix(slackText(merged), "<!channel>") {
+		t.Error("merged alert mentions the channel, though none of its alerts did")
+	}
+	if !strings.HasPrefix(slackText(got[5]), "<!channel>") {
+		t.Error("always-alert lost its mention")
+	}
+	if got[4].Severity != severityHigh {
+		t.Errorf("deleted user merged severity = %s, want high from the clone", got[4].Severity)
+	}
+}
+
+func TestCoalesceActorsUnchanged(t *testing.T) {
+	alerts := []*alert{
+		newAlert(entry("repo.destroy", "alice", "widgets", ago(time.Hour)), "", "alice destroyed widgets", repoSet{}),
+		newAlert(clone("bob", "one", ago(time.Hour)), "excessive clone[>=3]", "bob cloned one", repoSet{}),
+	}
+	got := coalesceActors(alerts)
+	if len(got) != 2 || got[0] != alerts[0] || got[1] != alerts[1] {
+		t.Errorf("coalesceActors changed alerts of actors who tripped one detector: %v", got)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// cooldownWindow is how long alerts for actions matching re are suppressed after one is sent; 0 never suppresses
+type cooldownWindow struct {
+	re     *regexp.Regexp
+	window time.Duration
+}
+
+// parseCooldowns parses "action-regexp=duration" pairs, matching actions in full and ignoring case like the
+// ignore lists
+func parseCooldowns(pairs []string) ([]cooldownWindow, error) {
+	ws := []cooldownWindow{}
+	for _, p := range pairs {
+		pattern, d, ok := strings.Cut(p, "=")
+		if !ok || pattern == "" || d == "" {
+			return nil, fmt.Errorf("%q is not in action-regexp=duration form", p)
+		}
+		re, err := regexp.Compile(fmt.Sprintf("(?i)^(?:%s)$", pattern))
+		if err != nil {
+			return nil, err
+		}
+		window, err := time.ParseDuration(d)
+		if err != nil || window < 0 {
+			return nil, fmt.Errorf("%q: %q is not a duration of at least 0", p, d)
+		}
+		ws = append(ws, cooldownWindow{re: re, window: window})
+	}
+	return ws, nil
+}
+
+// cooldown suppresses alerts repeating an action by the same actor on the same repo or org within the
+// action's window, recording when each was last sent in path so that the window spans runs
+type cooldown struct {
+	path    string
+	windows []cooldownWindow
+	// def is the window of actions matching no windows
+	def time.Duration
+
+	LastSent map[string]time.Time `json:"last_sent"`
+}
+
+// loadCooldown reads when alerts were last sent from path
+func loadCooldown(path string, windows []cooldownWindow, def time.Duration) (*cooldown, error) {
+	cd := &cooldown{path: path, windows: windows, def: def, LastSent: map[string]time.Time{}}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cd, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cd); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cd.LastSent == nil {
+		cd.LastSent = map[string]time.Time{}
+	}
+	return cd, nil
+}
+
+// window returns the cooldown of the first window matching action, or the default
+func (cd *cooldown) window(action string) time.Duration {
+	for _, w := range cd.windows {
+		if w.re.MatchString(action) {
+			return w.window
+		}
+	}
+	return cd.def
+}
+
+// cooldownKey identifies repeats of an alert: the same detector, action, actor, and repo or org
+func cooldownKey(al *alert) string {
+	a := al.Entry
+	return fmt.Sprintf("%s/%s/%s/%s", al.Kind, strings.ToLower(a.GetAction()), actorName(a), auditLocation(a))
+}
+
+// allow reports whether an alert may be sent, recording it as sent if so. Alerts without an entry, and for
+// always-alert actions, are never suppressed.
+func (cd *cooldown) allow(al *alert, now time.Time) bool {
+	if cd == nil || al.Entry == nil || alwaysAlert(al.Entry) {
+		return true
+	}
+	window := cd.window(al.Entry.GetAction())
+	if window == 0 {
+		return true
+	}
+
+	key := cooldownKey(al)
+	if last, ok := cd.LastSent[key]; ok && now.Sub(last) < window {
+		log.Printf("cooling down, %s last sent at %s: %s", key, last.Format(time.RFC3339), al)
+		return false
+	}
+	cd.LastSent[key] = now
+	return true
+}
+
+// save records when alerts were last sent, forgetting those whose window has ended
+func (cd *cooldown) save(now time.Time) error {
+	longest := cd.def
+	for _, w := range cd.windows {
+		longest = max(longest, w.window)
+	}
+	for k, last := range cd.LastSent {
+		if now.Sub(last) >= longest {
+			delete(cd.LastSent, k)
+		}
+	}
+
+	b, err := json.Marshal(cd)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cd.path, b)
+}
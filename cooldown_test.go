@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCooldownWindow(t *testing.T) {
+	windows, err := parseCooldowns([]string{`repo\.destroy=0`, `repo\..*=1h`, `Workflows\..*=10m`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd := &cooldown{windows: windows, def: 5 * time.Minute}
+	for action, want := range map[string]time.Duration{
+		// The first matching pattern wins, so repo.destroy is never suppressed
+		"repo.destroy":                     0,
+		"Repo.Destroy":                     0,
+		"repo.create":                      time.Hour,
+		"workflows.completed_workflow_run": 10 * time.Minute,
+		"org.add_member":                   5 * time.Minute,
+	} {
+		if got := cd.window(action); got != want {
+			t.Errorf("window(%s) = %s, want %s", action, got, want)
+		}
+	}
+
+	for _, bad := range []string{"repo.destroy", "=1h", "repo.destroy=", "repo.destroy=soon", "repo.destroy=-1h", "repo.(destroy=1h"} {
+		if _, err := parseCooldowns([]string{bad}); err == nil {
+			t.Errorf("parseCooldowns(%q) did not fail", bad)
+		}
+	}
+}
+
+func TestCooldownAllow(t *testing.T) {
+	windows, err := parseCooldowns([]string{`repo\.destroy=0`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cooldown.json")
+	cd, err := loadCooldown(path, windows, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := testNow
+	created := newAlert(entry("repo.create", "alice", "widgets", ago(time.Minute)), "", "", repoSet{})
+	if !cd.allow(created, now) {
+		t.Error("first alert suppressed")
+	}
+	if cd.allow(newAlert(entry("repo.create", "alice", "widgets", ago(0)), "", "", repoSet{}), now.Add(30*time.Minute)) {
+		t.Error("repeat within the window was not suppressed")
+	}
+	// Other actors, repos, and detectors, zero windows, always-alert actions, and alerts without entries are not repeats
+	for i, al := range []*alert{
+		newAlert(entry("repo.create", "bob", "widgets", ago(0)), "", "", repoSet{}),
+		newAlert(entry("repo.create", "alice", "gadgets", ago(0)), "", "", repoSet{}),
+		newAlert(entry("repo.create", "alice", "widgets", ago(0)), "repo creation burst[>=3]", "", repoSet{}),
+		newAlert(entry("repo.destroy", "alice", "widgets", ago(0)), "", "", repoSet{}),
+		newAlert(entry("repo.destroy", "alice", "widgets", ago(0)), "", "", repoSet{}),
+		newAlert(entry("org.disable_two_factor_requirement", "alice", "", ago(0)), "", "", repoSet{}),
+		newAlert(entry("org.disable_two_factor_requirement", "alice", "", ago(0)), "", "", repoSet{}),
+		newAlert(nil, "", "no alerts", repoSet{}),
+	} {
+		if !cd.allow(al, now.Add(30*time.Minute)) {
+			t.Errorf("alert %d suppressed: %s", i, al)
+		}
+	}
+
+	// The window spans runs, and ends after its duration
+	if err := cd.save(now.Add(30 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	cd, err = loadCooldown(path, windows, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cd.allow(created, now.Add(59*time.Minute)) {
+		t.Error("repeat in the next run was not suppressed")
+	}
+	if !cd.allow(created, now.Add(61*time.Minute)) {
+		t.Error("repeat after the window was suppressed")
+	}
+
+	// Alerts whose window has ended are forgotten
+	if err := cd.save(now.Add(3 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(cd.LastSent) != 0 {
+		t.Errorf("LastSent = %v, want expired alerts forgotten", cd.LastSent)
+	}
+}
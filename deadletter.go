@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// deadLetters records alerts that no remote notifier delivered as NDJSON, so that a later run can retry them.
+// Alerts are recorded before --redact is applied, so that the retry redacts them like any other alert.
+type deadLetters struct {
+	path string
+	// maxBytes caps the file size; alerts that would grow it further are dropped
+	maxBytes int64
+}
+
+// add appends an undelivered alert to the file
+func (d *deadLetters) add(al *alert) error {
+	b, err := json.Marshal(al)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if fi, err := os.Stat(d.path); err == nil && fi.Size()+int64(len(b)) > d.maxBytes {
+		return fmt.Errorf("%s is full, dropping alert: %s", d.path, al)
+	}
+
+	f, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	log.Printf("[dead letter] %s", al)
+	return f.Close()
+}
+
+// retry re-sends the recorded alerts. Those that fail again are recorded anew by stats.
+func (d *deadLetters) retry(ctx context.Context, ns []notifier, stats *runStats) error {
+	// Move the file aside first, so that alerts failing again are appended to a fresh one
+	retrying := d.path + ".retry"
+	if err := os.Rename(d.path, retrying); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	f, err := os.Open(retrying)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	als := []*alert{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		al := &alert{}
+		if err := json.Unmarshal(sc.Bytes(), al); err != nil {
+			log.Printf("skipping unreadable dead letter: %v", err)
+			continue
+		}
+		als = append(als, al)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", retrying, err)
+	}
+
+	log.Printf("retrying %d undelivered alerts", len(als))
+	for _, al := range als {
+		stats.notify(ctx, ns, al)
+	}
+	return os.Remove(retrying)
+}
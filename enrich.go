@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// enricher adds details to an alert before it is delivered
+type enricher interface {
+	Enrich(ctx context.Context, al *alert) error
+}
+
+// newEnrichers returns the enrichers named in a comma separated list, in order
+func newEnrichers(c *github.Client, list string) ([]enricher, error) {
+	es := []enricher{}
+	for _, name := range strings.Split(list, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "user-name":
+			es = append(es, &userNameEnricher{c: c, names: map[string]string{}})
+		case "profile-link":
+			es = append(es, profileLinkEnricher{})
+		case "repo-fork-status":
+			es = append(es, &repoForkEnricher{c: c, parents: map[string]string{}})
+		default:
+			return nil, fmt.Errorf("unknown enricher %q, expected user-name, profile-link, or repo-fork-status", name)
+		}
+	}
+	return es, nil
+}
+
+// enrich applies each enricher in turn. Failures are logged, and the alert is delivered without that detail.
+func enrich(ctx context.Context, es []enricher, al *alert) {
+	for _, e := range es {
+		if err := e.Enrich(ctx, al); err != nil {
+			log.Printf("enrich failed: %v", err)
+		}
+	}
+}
+
+// userNameEnricher adds the actor's display name
+type userNameEnricher struct {
+	c     *github.Client
+	names map[string]string
+}
+
+func (e *userNameEnricher) Enrich(ctx context.Context, al *alert) error {
+	actor := al.Entry.GetActor()
+	if actor == "" || strings.HasSuffix(actor, "[bot]") {
+		return nil
+	}
+
+	name, ok := e.names[actor]
+	if !ok {
+		u, _, err := e.c.Users.Get(ctx, actor)
+		if err != nil {
+			return fmt.Errorf("get user %s: %w", actor, err)
+		}
+		name = u.GetName()
+		e.names[actor] = name
+	}
+
+	if name != "" {
+		al.addDetail("actor name", fmt.Sprintf("%q", name))
+	}
+	return nil
+}
+
+// profileLinkEnricher adds a link to the actor's GitHub profile
+type profileLinkEnricher struct{}
+
+func (profileLinkEnricher) Enrich(_ context.Context, al *alert) error {
+	if al.Entry == nil {
+		return nil
+	}
+	if link := msgFields["profile"].render(al.Entry); link != "" {
+		al.addDetail("profile", link)
+	}
+	return nil
+}
+
+// repoForkEnricher adds the repository a forked repository was forked from
+type repoForkEnricher struct {
+	c *github.Client
+	// parents holds the parent of each repo looked up, or "" if it is not a fork
+	parents map[string]string
+}
+
+func (e *repoForkEnricher) Enrich(ctx context.Context, al *alert) error {
+	owner, repo, ok := strings.Cut(al.Entry.GetRepo(), "/")
+	if !ok {
+		return nil
+	}
+
+	full := al.Entry.GetRepo()
+	parent, ok := e.parents[full]
+	if !ok {
+		r, _, err := e.c.Repositories.Get(ctx, owner, repo)
+		if err != nil {
+			return fmt.Errorf("get repo %s: %w", full, err)
+		}
+		parent = r.GetParent().GetFullName()
+		e.parents[full] = parent
+	}
+
+	if parent != "" {
+		al.addDetail("fork of", parent)
+	}
+	return nil
+}
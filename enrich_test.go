@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v53/github"
+)
+
+func TestNewEnrichers(t *testing.T) {
+	for _, tc := range []struct {
+		list    string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"user-name,profile-link,repo-fork-status", 3, false},
+		{" profile-link , ", 1, false},
+		{"geoip", 0, true},
+	} {
+		es, err := newEnrichers(nil, tc.list)
+		if (err != nil) != tc.wantErr || len(es) != tc.want {
+			t.Errorf("newEnrichers(%q) = %d enrichers, %v; want %d, error %v", tc.list, len(es), err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+// failingEnricher always fails, without adding a detail
+type failingEnricher struct{}
+
+func (failingEnricher) Enrich(context.Context, *alert) error {
+	return fmt.Errorf("lookup failed")
+}
+
+func TestEnrich(t *testing.T) {
+	userLookups := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/alice", func(w http.ResponseWriter, _ *http.Request) {
+		userLookups++
+		fmt.Fprint(w, `{"login": "alice", "name": "Alice Liddell"}`)
+	})
+	mux.HandleFunc("/users/bob", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/repos/acme/widgets", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"full_name": "acme/widgets", "fork": true, "parent": {"full_name": "upstream/widgets"}}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := github.NewClient(nil)
+	c.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	es, err := newEnrichers(c, "user-name,profile-link,repo-fork-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	es = append([]enricher{failingEnricher{}}, es...)
+
+	for _, tc := range []struct {
+		actor string
+		want  []alertDetail
+	}{
+		{"alice", []alertDetail{
+			{"actor name", `"Alice Liddell"`},
+			{"profile", "<https://github.com/alice|@alice>"},
+			{"fork of", "upstream/widgets"},
+		}},
+		// A failing lookup only loses its own detail
+		{"bob", []alertDetail{
+			{"profile", "<https://github.com/bob|@bob>"},
+			{"fork of", "upstream/widgets"},
+		}},
+		{"alice", []alertDetail{
+			{"actor name", `"Alice Liddell"`},
+			{"profile", "<https://github.com/alice|@alice>"},
+			{"fork of", "upstream/widgets"},
+		}},
+	} {
+		al := newAlert(entry("repo.add_member", tc.actor, "widgets", ago(0)), "", "added", repoSet{})
+		enrich(context.Background(), es, al)
+		if fmt.Sprint(al.Details) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: details = %v, want %v", tc.actor, al.Details, tc.want)
+		}
+	}
+	if userLookups != 1 {
+		t.Errorf("looked up alice %d times, want once", userLookups)
+	}
+}
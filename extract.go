@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// extraction surfaces the capture groups of a regexp applied to one field of an audit entry
+type extraction struct {
+	// field is the entry's JSON field name, such as "explanation"
+	field string
+	re    *regexp.Regexp
+}
+
+// parseExtractions parses "field=regexp" rules. Each regexp must have a capture group; named groups
+// become details with that name, and unnamed ones are named after the field and group number.
+func parseExtractions(rules []string) ([]extraction, error) {
+	es := []extraction{}
+	for _, r := range rules {
+		field, pattern, ok := strings.Cut(r, "=")
+		if !ok || field == "" || pattern == "" {
+			return nil, fmt.Errorf("%q is not in field=regexp form", r)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+		if re.NumSubexp() == 0 {
+			return nil, fmt.Errorf("%s: %q has no capture groups", field, pattern)
+		}
+		es = append(es, extraction{field: field, re: re})
+	}
+	return es, nil
+}
+
+// extractEnricher adds the values captured by each extraction as details
+type extractEnricher struct {
+	rules []extraction
+}
+
+func (e extractEnricher) Enrich(_ context.Context, al *alert) error {
+	if al.Entry == nil {
+		return nil
+	}
+
+	// Fields are looked up by their names in the audit log API, so rules can name any field the client keeps
+	b, err := json.Marshal(al.Entry)
+	if err != nil {
+		return err
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+
+	for _, r := range e.rules {
+		v, ok := fields[r.field]
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+		m := r.re.FindStringSubmatch(s)
+		for i, name := range r.re.SubexpNames() {
+			if i == 0 || i >= len(m) || m[i] == "" {
+				continue
+			}
+			if name == "" {
+				name = fmt.Sprintf("%s_%d", r.field, i)
+			}
+			al.addDetail(name, m[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// repoFilter scopes alerts to repositories: deny always skips a repo, and a non-empty allow skips the rest
+type repoFilter struct {
+	allow repoSet
+	deny  repoSet
+}
+
+// skip reports whether alerts about a repo should be skipped. Entries without a repo are never skipped.
+func (f *repoFilter) skip(repo string) bool {
+	if f == nil || repo == "" {
+		return false
+	}
+	if f.deny.has(repo) {
+		return true
+	}
+	if len(f.allow.names) == 0 && len(f.allow.patterns) == 0 {
+		return false
+	}
+	return !f.allow.has(repo)
+}
+
+// readRepoFilter reads a YAML file of the form:
+//
+//	allow:
+//	  - repo
+//	deny:
+//	  - "*-sandbox"
+//
+// Only these two lists of strings are supported. Bare names and patterns are prefixed with org.
+func readRepoFilter(path string, org string) (*repoFilter, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := map[string][]string{}
+	section := ""
+	for i, line := range strings.Split(string(b), "\n") {
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case line == "allow:" || line == "deny:":
+			section = strings.TrimSuffix(line, ":")
+		case strings.HasPrefix(line, "- ") && section != "":
+			lists[section] = append(lists[section], strings.Trim(strings.TrimSpace(line[2:]), `"'`))
+		default:
+			return nil, fmt.Errorf("%s:%d: expected allow:, deny:, or a \"- repo\" list item", path, i+1)
+		}
+	}
+
+	f := &repoFilter{}
+	if f.allow, err = normalizeRepos(org, lists["allow"]); err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	if f.deny, err = normalizeRepos(org, lists["deny"]); err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	return f, nil
+}
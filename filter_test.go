@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// writeRepoFilter writes a repo filter file and reads it back
+func writeRepoFilter(t *testing.T, yaml string) (*repoFilter, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "filter.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return readRepoFilter(path, testOrg)
+}
+
+func TestRepoFilterSkip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		yaml string
+		skip map[string]bool
+	}{
+		{
+			name: "deny wins over allow",
+			yaml: "allow:\n  - \"*-prod\"\n  - widgets\ndeny:\n  - legacy-prod # too noisy\n",
+			skip: map[string]bool{
+				"acme/api-prod":    false,
+				"acme/Widgets":     false,
+				"acme/legacy-prod": true,
+				"acme/api-dev":     true,
+				"":                 false,
+			},
+		},
+		{
+			name: "empty allow means every repo",
+			yaml: "deny:\n  - '*-sandbox'\n",
+			skip: map[string]bool{
+				"acme/api-dev":     false,
+				"acme/api-sandbox": true,
+				"other/x-sandbox":  false,
+			},
+		},
+		{
+			name: "a repo both allowed and denied is denied",
+			yaml: "allow:\n  - widgets\ndeny:\n  - widgets\n",
+			skip: map[string]bool{
+				"acme/widgets": true,
+				"acme/gadgets": true,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := writeRepoFilter(t, tc.yaml)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for repo, want := range tc.skip {
+				if got := f.skip(repo); got != want {
+					t.Errorf("skip(%q) = %v, want %v", repo, got, want)
+				}
+			}
+		})
+	}
+
+	var none *repoFilter
+	if none.skip("acme/widgets") {
+		t.Error("a nil filter skipped a repo")
+	}
+}
+
+func TestReadRepoFilterErrors(t *testing.T) {
+	for _, yaml := range []string{
+		"allow: widgets\n",
+		"- widgets\n",
+		"deny:\n  - \"[-prod\"\n",
+	} {
+		if _, err := writeRepoFilter(t, yaml); err == nil {
+			t.Errorf("readRepoFilter accepted %q", yaml)
+		}
+	}
+}
+
+func TestRepoFilterWebEvents(t *testing.T) {
+	f, err := writeRepoFilter(t, "allow:\n  - \"*-prod\"\ndeny:\n  - legacy-prod\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Settings{
+		Org:                      testOrg,
+		Since:                    ago(time.Hour),
+		RepoFilter:               f,
+		GlobalIgnoreActions:      []string{"org.update_member"},
+		NonCriticalIgnoreActions: []string{"repo.add_topic"},
+	}
+	entries := []*github.AuditEntry{
+		entry("repo.destroy", "alice", "api-prod", ago(40*time.Minute)),
+		entry("repo.destroy", "alice", "legacy-prod", ago(30*time.Minute)),
+		entry("repo.destroy", "alice", "api-dev", ago(20*time.Minute)),
+		// Org-wide entries are not scoped by repo
+		entry("org.add_member", "alice", "", ago(10*time.Minute)),
+	}
+	want := []string{"alice repo.destroy acme/api-prod", "alice org.add_member"}
+	if got := webEvents(entries, s); !equalStrings(actions(got), want) {
+		t.Errorf("webEvents = %q, want %q", actions(got), want)
+	}
+}
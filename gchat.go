@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// gchatMaxText is the most characters Google Chat shows in a message's text
+const gchatMaxText = 4096
+
+// gchatMessage is the subset of the Google Chat message format used for alerts
+type gchatMessage struct {
+	Text    string      `json:"text"`
+	CardsV2 []gchatCard `json:"cardsV2,omitempty"`
+}
+
+type gchatCard struct {
+	CardID string `json:"cardId"`
+	Card   struct {
+		Header   gchatHeader    `json:"header"`
+		Sections []gchatSection `json:"sections"`
+	} `json:"card"`
+}
+
+type gchatHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type gchatSection struct {
+	Widgets []gchatWidget `json:"widgets"`
+}
+
+type gchatWidget struct {
+	DecoratedText *gchatDecoratedText `json:"decoratedText,omitempty"`
+	ButtonList    *gchatButtonList    `json:"buttonList,omitempty"`
+}
+
+type gchatDecoratedText struct {
+	TopLabel string `json:"topLabel"`
+	Text     string `json:"text"`
+}
+
+type gchatButtonList struct {
+	Buttons []gchatButton `json:"buttons"`
+}
+
+type gchatButton struct {
+	Text    string `json:"text"`
+	OnClick struct {
+		OpenLink struct {
+			URL string `json:"url"`
+		} `json:"openLink"`
+	} `json:"onClick"`
+}
+
+// gchatNotifier posts alerts to a Google Chat incoming webhook, with a card of the entry's key fields
+type gchatNotifier struct {
+	url string
+}
+
+// gchatPayload builds the message for an alert; alerts without an entry are sent as text only
+func gchatPayload(al *alert) gchatMessage {
+	msg := gchatMessage{Text: truncate(al.String(), gchatMaxText)}
+	a := al.Entry
+	if a == nil {
+		return msg
+	}
+
+	card := gchatCard{CardID: al.fingerprint()}
+	card.Card.Header = gchatHeader{Title: alertTitle(a, al.String()), Subtitle: al.Severity}
+
+	fields := gchatSection{}
+	for _, f := range []struct{ label, text string }{
+		{"Actor", actorName(a)},
+		{"Action", a.GetAction()},
+		{"Location", auditLocation(a)},
+		{"Time", formatTime(a.GetTimestamp().Time)},
+	} {
+		if f.text != "" {
+			fields.Widgets = append(fields.Widgets, gchatWidget{DecoratedText: &gchatDecoratedText{TopLabel: f.label, Text: f.text}})
+		}
+	}
+
+	button := gchatButton{Text: "Audit log"}
+	button.OnClick.OpenLink.URL = auditLogURL(a)
+	fields.Widgets = append(fields.Widgets, gchatWidget{ButtonList: &gchatButtonList{Buttons: []gchatButton{button}}})
+
+	card.Card.Sections = []gchatSection{fields}
+	msg.CardsV2 = []gchatCard{card}
+	return msg
+}
+
+func (n gchatNotifier) Notify(ctx context.Context, al *alert) error {
+	b, err := json.Marshal(gchatPayload(al))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	log.Printf("[gchat post] %s", al)
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("gchat: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("gchat: %s: %s", resp.Status, body)
+	}
+	return nil
+}
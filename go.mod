@@ -1,9 +1,11 @@
 module github.com/chainguard-dev/github-audit-alerter
 
-go 1.23
+go 1.23.0
 
 require (
-	github.com/google/go-github/v51 v51.0.0
+	github.com/google/go-github/v53 v53.2.0
+	github.com/google/go-querystring v1.1.0
+	github.com/segmentio/kafka-go v0.4.51
 	github.com/slack-go/slack v0.15.0
 	golang.org/x/oauth2 v0.24.0
 )
@@ -11,8 +13,9 @@ require (
 require (
 	github.com/ProtonMail/go-crypto v1.0.0 // indirect
 	github.com/cloudflare/circl v1.3.9 // indirect
-	github.com/google/go-querystring v1.1.0 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 	golang.org/x/crypto v0.24.0 // indirect
 	golang.org/x/sys v0.21.0 // indirect
 )
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// hookPayload is written to --post-run-hook's stdin as JSON. Fields are only ever added, so hooks
+// should ignore ones they do not know.
+type hookPayload struct {
+	Org   string    `json:"org"`
+	Since time.Time `json:"since"`
+	// Scanned, Attempted, Delivered, Held, and Failures are as in the run summary log line
+	Scanned   int `json:"scanned"`
+	Attempted int `json:"attempted"`
+	Delivered int `json:"delivered"`
+	Held      int `json:"held"`
+	Failures  int `json:"failures"`
+	// Counts tallies the alerts by category, as in --summary-only
+	Counts alertCounts `json:"counts"`
+	// Alerts are all those found by the run, in order, whether or not they were sent
+	Alerts []*alert `json:"alerts"`
+}
+
+// runHook runs command with sh, passing the payload on stdin, and the counts in the environment as
+// GH_AUDIT_ALERTS, GH_AUDIT_ATTEMPTED, GH_AUDIT_DELIVERED, and GH_AUDIT_FAILURES. It is killed after timeout.
+func runHook(ctx context.Context, command string, timeout time.Duration, p hookPayload) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("GH_AUDIT_ALERTS=%d", len(p.Alerts)),
+		fmt.Sprintf("GH_AUDIT_ATTEMPTED=%d", p.Attempted),
+		fmt.Sprintf("GH_AUDIT_DELIVERED=%d", p.Delivered),
+		fmt.Sprintf("GH_AUDIT_FAILURES=%d", p.Failures),
+	)
+
+	log.Printf("running post-run hook with %d alerts", len(p.Alerts))
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("post-run hook timed out after %s", timeout)
+		}
+		return fmt.Errorf("post-run hook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// issueLabel is applied to every alert issue, and used to find open ones
+const issueLabel = "audit-alert"
+
+var issueFingerprintRe = regexp.MustCompile(`(?m)^fingerprint: (\S+)$`)
+
+// issueNotifier files alerts as issues in a GitHub repository
+type issueNotifier struct {
+	c     *github.Client
+	owner string
+	repo  string
+
+	// open holds the fingerprints of open alert issues, populated on first use
+	open map[string]bool
+}
+
+func newIssueNotifier(c *github.Client, fullName string) (*issueNotifier, error) {
+	owner, repo, ok := strings.Cut(fullName, "/")
+	if !ok || owner == "" || repo == "" {
+		return nil, fmt.Errorf("%q is not in owner/repo form", fullName)
+	}
+	return &issueNotifier{c: c, owner: owner, repo: repo}, nil
+}
+
+// openFingerprints returns the fingerprints of alert issues that are still open
+func (n *issueNotifier) openFingerprints(ctx context.Context) (map[string]bool, error) {
+	if n.open != nil {
+		return n.open, nil
+	}
+
+	open := map[string]bool{}
+	opts := &github.IssueListByRepoOptions{State: "open", Labels: []string{issueLabel}}
+	opts.ListOptions.PerPage = 100
+
+	for {
+		issues, resp, err := n.c.Issues.ListByRepo(ctx, n.owner, n.repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range issues {
+			if m := issueFingerprintRe.FindStringSubmatch(i.GetBody()); m != nil {
+				open[m[1]] = true
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	n.open = open
+	return open, nil
+}
+
+func (n *issueNotifier) Notify(ctx context.Context, al *alert) error {
+	open, err := n.openFingerprints(ctx)
+	if err != nil {
+		return fmt.Errorf("list issues: %w", err)
+	}
+
+	fp := al.fingerprint()
+	if open[fp] {
+		log.Printf("issue already open for %s, skipping", fp)
+		return nil
+	}
+
+	// Alerter errors share a fingerprint so that only one is open at a time
+	req := &github.IssueRequest{
+		Title:  github.String(alertTitle(al.Entry, al.String())),
+		Body:   github.String(fmt.Sprintf("%s\n\n```json\n%s\n```\n\nfingerprint: %s\n", al, auditString(al.Entry), fp)),
+		Labels: &[]string{issueLabel},
+	}
+
+	i, _, err := n.c.Issues.Create(ctx, n.owner, n.repo, req)
+	if err != nil {
+		return fmt.Errorf("create issue: %w", err)
+	}
+
+	log.Printf("[issue created] %s", i.GetHTMLURL())
+	open[fp] = true
+	return nil
+}
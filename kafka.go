@@ -0,0 +1,92 @@
+//go:build kafka
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	kafkaBrokersFlag = flag.String("kafka-brokers", "", "comma separated host:port Kafka bootstrap brokers to produce alerts to, with --kafka-topic")
+	kafkaTopicFlag   = flag.String("kafka-topic", "", "Kafka topic to produce each alert to as JSON, keyed by its fingerprint")
+	kafkaTLSFlag     = flag.Bool("kafka-tls", false, "connect to the Kafka brokers with TLS")
+)
+
+func init() {
+	optionalNotifiers = append(optionalNotifiers, newKafkaNotifier)
+}
+
+// kafkaTimeout bounds each produce request, so that an unresponsive cluster fails a delivery
+const kafkaTimeout = 30 * time.Second
+
+// kafkaWriter is the part of kafka.Writer used, so that tests can stand in for a cluster
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaNotifier produces each alert to a Kafka topic as JSON, keyed by its fingerprint so that alerts
+// about the same entry land on the same partition. Each alert waits for every in-sync replica to acknowledge
+// it, so a failed delivery is counted like any other sink's.
+type kafkaNotifier struct {
+	w kafkaWriter
+}
+
+// newKafkaNotifier returns a notifier for --kafka-brokers and --kafka-topic, or nil if they are not set
+func newKafkaNotifier() (notifier, error) {
+	if *kafkaBrokersFlag == "" && *kafkaTopicFlag == "" {
+		return nil, nil
+	}
+	if *kafkaBrokersFlag == "" || *kafkaTopicFlag == "" {
+		return nil, errors.New("--kafka-brokers and --kafka-topic must be given together")
+	}
+
+	brokers := []string{}
+	for _, b := range strings.Split(*kafkaBrokersFlag, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+
+	tr := &kafka.Transport{ClientID: "github-audit-alerter"}
+	if *kafkaTLSFlag {
+		tr.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return &kafkaNotifier{w: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        *kafkaTopicFlag,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		WriteTimeout: kafkaTimeout,
+		Transport:    tr,
+	}}, nil
+}
+
+func (n *kafkaNotifier) Notify(ctx context.Context, al *alert) error {
+	value, err := json.Marshal(al)
+	if err != nil {
+		return err
+	}
+	key := al.fingerprint()
+
+	log.Printf("[kafka produce] %s", key)
+	if err := n.w.WriteMessages(ctx, kafka.Message{Key: []byte(key), Value: value}); err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	return nil
+}
+
+// Flush closes the connections to the brokers at the end of a run
+func (n *kafkaNotifier) Flush(_ context.Context) error {
+	return n.w.Close()
+}
@@ -0,0 +1,105 @@
+//go:build kafka
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafka records the messages written to it, or fails them with err
+type fakeKafka struct {
+	msgs   []kafka.Message
+	err    error
+	closed bool
+}
+
+func (f *fakeKafka) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.msgs = append(f.msgs, msgs...)
+	return nil
+}
+
+func (f *fakeKafka) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestKafkaNotify(t *testing.T) {
+	a := entry("repo.destroy", "alice", "widgets", ago(time.Minute))
+	al := newAlert(a, "", "alice destroyed widgets", repoSet{})
+
+	w := &fakeKafka{}
+	n := &kafkaNotifier{w: w}
+	if err := n.Notify(context.Background(), al); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.msgs) != 1 {
+		t.Fatalf("%d messages written, want 1", len(w.msgs))
+	}
+	if got := string(w.msgs[0].Key); got != fingerprint(a) {
+		t.Errorf("key = %q, want the fingerprint %q", got, fingerprint(a))
+	}
+	var got alert
+	if err := json.Unmarshal(w.msgs[0].Value, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Message != al.Message || got.Entry.GetAction() != "repo.destroy" {
+		t.Errorf("value = %s, want the alert's JSON", w.msgs[0].Value)
+	}
+
+	if err := n.Flush(context.Background()); err != nil || !w.closed {
+		t.Errorf("Flush = %v, closed %v, want the writer closed", err, w.closed)
+	}
+
+	// Alerts without an entry, such as summaries, are keyed by their own fingerprints rather than sharing one
+	w = &fakeKafka{}
+	n = &kafkaNotifier{w: w}
+	for _, msg := range []string{"3 alerts for acme", "+2 more events for acme"} {
+		if err := n.Notify(context.Background(), newAlert(nil, "", msg, repoSet{})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if k1, k2 := string(w.msgs[0].Key), string(w.msgs[1].Key); k1 == k2 || k1 == fingerprint(nil) {
+		t.Errorf("summary keys = %q, %q, want distinct alert fingerprints", k1, k2)
+	}
+
+	n = &kafkaNotifier{w: &fakeKafka{err: errors.New("not enough replicas")}}
+	if err := n.Notify(context.Background(), al); err == nil {
+		t.Error("Notify did not return the produce error")
+	}
+}
+
+func TestNewKafkaNotifier(t *testing.T) {
+	for _, tc := range []struct {
+		brokers, topic string
+		wantNil        bool
+		wantErr        bool
+	}{
+		{"", "", true, false},
+		{"b1:9092", "", false, true},
+		{"", "alerts", false, true},
+		{"b1:9092, b2:9092", "alerts", false, false},
+	} {
+		*kafkaBrokersFlag, *kafkaTopicFlag = tc.brokers, tc.topic
+		n, err := newKafkaNotifier()
+		if (err != nil) != tc.wantErr || (n == nil) != (tc.wantNil || tc.wantErr) {
+			t.Errorf("brokers %q topic %q: newKafkaNotifier = %v, %v", tc.brokers, tc.topic, n, err)
+		}
+		if n == nil {
+			continue
+		}
+		w := n.(*kafkaNotifier).w.(*kafka.Writer)
+		if w.Addr.String() != "b1:9092,b2:9092" || w.Topic != "alerts" || w.RequiredAcks != kafka.RequireAll {
+			t.Errorf("writer = %s %s %v", w.Addr, w.Topic, w.RequiredAcks)
+		}
+	}
+	*kafkaBrokersFlag, *kafkaTopicFlag = "", ""
+}
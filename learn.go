@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"time"
+)
+
+// learnedActions records when unclassified actions were first seen, so that
+// alerts for them are held back while operators decide how to classify them
+type learnedActions struct {
+	path  string
+	grace time.Duration
+
+	FirstSeen map[string]time.Time `json:"first_seen"`
+}
+
+// loadLearnedActions reads the actions seen so far from path
+func loadLearnedActions(path string, grace time.Duration) (*learnedActions, error) {
+	l := &learnedActions{path: path, grace: grace, FirstSeen: map[string]time.Time{}}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if l.FirstSeen == nil {
+		l.FirstSeen = map[string]time.Time{}
+	}
+	return l, nil
+}
+
+// suppress records an unclassified action, and reports whether it is still within its grace period
+func (l *learnedActions) suppress(action string, now time.Time) bool {
+	first, ok := l.FirstSeen[action]
+	if !ok {
+		log.Printf("new action %q, suppressing alerts for it until %s", action, now.Add(l.grace))
+		l.FirstSeen[action] = now
+		return true
+	}
+	return now.Sub(first) < l.grace
+}
+
+// classifiedActions are the action patterns that are ignored, alerted on by design, or surfaced by an
+// opt-in detector, whether or not it is enabled. Other actions are held back by --learn-new-actions.
+func classifiedActions(s Settings) []string {
+	return slices.Concat(
+		s.GlobalIgnoreActions,
+		s.NonCriticalIgnoreActions,
+		alwaysAlertActions,
+		alertingActions,
+		securityDowngradeActions,
+		workflowPermsActions,
+		auditAccessActions,
+		appInstallActions,
+		dismissalActions,
+		autoDismissalActions,
+		orgSecretActions,
+		forcePushActions,
+		featureToggleActions,
+		teamRepoPermissionActions,
+	)
+}
+
+func (l *learnedActions) save() error {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(l.path, b)
+}
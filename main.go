@@ -3,25 +3,46 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
 
-	"github.com/google/go-github/v51/github"
+	"github.com/google/go-github/v53/github"
 	"github.com/slack-go/slack"
 )
 
 var (
+	// version, commit, and date identify this build of the alerter, set with -ldflags "-X main.version=..."
+	version = "devel"
+	commit  = "unknown"
+	date    = "unknown"
+
+	// alwaysAlertActions are too dangerous to ever be ignored: they are critical, and skip every
+	// ignore list, filter, and trusted actor or IP
+	alwaysAlertActions = []string{
+		"org.disable_two_factor_requirement",
+	}
+
 	// universalIgnore are regexps for actions to ignore globally
 	universalIgnore = []string{
 		"account.plan_change",
@@ -106,45 +127,435 @@ var (
 		"required_status_check.destroy",
 		"team.*",
 	}
+
+	// alertingActions are alerted on by design, so --learn-new-actions never treats them as unclassified
+	alertingActions = []string{
+		"hook.create",
+		"hook.destroy",
+		"oauth_application.create",
+		"org.add_billing_manager",
+		"org.block_user",
+		"org.remove_member",
+		"org.remove_outside_collaborator",
+		"org.update_default_repository_permission",
+		"org.update_member",
+		"org.update_member_repository_creation_permission",
+		"personal_access_token.access_granted",
+		"public_key.create",
+		"repo.access",
+		"repo.add_member",
+		"repo.destroy",
+		"repo.transfer",
+		"repo.transfer_outgoing",
+		"repo.update_member",
+	}
+
+	// securityDowngradeActions disable security features, surfaced by --alert-security-downgrade.
+	// The matching enable actions remain ignored.
+	securityDowngradeActions = []string{
+		"advanced_security.disabled_for_new_repos",
+		"advanced_security.disabled_on_all_repos",
+		"dependabot_alerts.disable",
+		"dependabot_alerts_new_repos.disable",
+		"dependabot_security_updates.disable",
+		"dependabot_security_updates_new_repos.disable",
+		"dependency_graph.disable",
+		"dependency_graph_new_repos.disable",
+		"repo.advanced_security_disabled",
+		"repository_dependency_graph.disable",
+		"repository_secret_scanning.disable",
+		"repository_secret_scanning_push_protection.disable",
+		"repository_vulnerability_alerts.disable",
+		"secret_scanning.disable",
+		"secret_scanning_new_repos.disable",
+		"secret_scanning_push_protection.disable",
+	}
+
+	// workflowPermsActions change what GitHub Actions workflows may do, surfaced by --alert-workflow-perms
+	// when they grant more access, see workflowPermsEscalation
+	workflowPermsActions = []string{
+		"org.set_default_workflow_permissions",
+		"org.set_workflow_permission_can_approve_pr",
+		"repo.set_default_workflow_permissions",
+		"repo.set_workflow_permission_can_approve_pr",
+	}
+
+	// auditAccessActions export the audit log or change where it is streamed, surfaced by --alert-audit-access
+	auditAccessActions = []string{
+		"audit_log_streaming.create",
+		"audit_log_streaming.destroy",
+		"audit_log_streaming.update",
+		"org.audit_log_export",
+		"org.audit_log_git_event_export",
+	}
+
+	// appInstallActions install a GitHub App or accept its new permissions, surfaced by --alert-app-installs.
+	// Adding or removing repositories from an existing installation remains ignored.
+	appInstallActions = []string{
+		"integration_installation.create",
+		"integration_installation.version_updated",
+	}
+
+	// dismissalActions close Dependabot or secret scanning alerts by hand, surfaced by --alert-dismissals
+	dismissalActions = []string{
+		"repository_vulnerability_alert.dismiss",
+		"repository_vulnerability_alert.resolve",
+		"secret_scanning_alert.resolve",
+	}
+
+	// autoDismissalActions are closed by Dependabot's own rules, surfaced by --alert-dismissals with --alert-auto-dismissals
+	autoDismissalActions = []string{
+		"repository_vulnerability_alert.auto_dismiss",
+	}
+
+	// orgSecretActions change GitHub Actions secrets and variables shared with every repo, surfaced by
+	// --alert-org-secrets. Repo-level secret actions are handled by the ignore lists as usual.
+	orgSecretActions = []string{
+		"org.create_actions_secret",
+		"org.create_actions_variable",
+		"org.remove_actions_secret",
+		"org.remove_actions_variable",
+		"org.update_actions_secret",
+		"org.update_actions_variable",
+	}
+
+	// forcePushActions bypass or weaken branch protection against force pushes, or are pushes it rejected,
+	// surfaced by --alert-force-pushes. The audit log has no entry for a force push that was allowed.
+	forcePushActions = []string{
+		"protected_branch.policy_override",
+		"protected_branch.rejected_ref_update",
+		"protected_branch.update_allow_force_pushes_enforcement_level",
+	}
+
+	// featureToggleActions turn Dependabot, the dependency graph, or GitHub Actions on or off for a repo. The disables
+	// are surfaced by --alert-feature-disabled, see featureDisabled, while the enables remain ignored.
+	featureToggleActions = []string{
+		"dependabot_security_updates.*",
+		"repo.actions_.*",
+		"repository_dependency_graph.*",
+		"repository_vulnerability_alerts.*",
+	}
+
+	// teamRepoPermissionActions give a team access to a repo or change it, surfaced by --alert-team-admin
+	// when they grant admin, see teamAdminGrant
+	teamRepoPermissionActions = []string{
+		"team.add_repository",
+		"team.update_repository_permission",
+	}
+
+	// actionEmoji maps action regexps to the emoji prepended to alerts with --emoji; the first match wins
+	actionEmoji = []struct {
+		pattern string
+		emoji   string
+	}{
+		{"git.clone", "📦"},
+		{"public_key.*", "🔑"},
+		{"personal_access_token.*", "🔑"},
+		{"repo.add_deploy_key", "🔑"},
+		{".*secret.*", "🤫"},
+		{"repo.access", "👁"},
+		{".*visibility.*", "👁"},
+		{"org.(add|remove|invite)_.*", "👤"},
+		{"repo.(add|remove|update)_member", "👤"},
+		{"team.*", "👥"},
+		{"hook.*", "🪝"},
+		{"protected_branch.*", "🛡"},
+		{"repo.(destroy|archived|transfer|rename)", "🗑"},
+	}
+
+	// defaultEmoji is used with --emoji for actions not found in actionEmoji
+	defaultEmoji = "⚠️"
 )
 
 var (
-	intervalFlag       = flag.Duration("interval", 15*time.Minute, "How far to go backwards searching for actions to alert on")
-	maxReposClonedFlag = flag.Int("max-repos-cloned-per-user", 5, "minimum repositories to see cloned before creating a user alert")
-	cloneIntervalFlag  = flag.Duration("clone-search-interval", 24*time.Hour, "How far to go backwards searching for git clone events")
-	criticalReposFlag  = flag.String("critical-repos", "", "critical repositories for more stringent checking, comma separated")
-	orgFlag            = flag.String("org", "", "Github Organization to query")
-	botNameFlag        = flag.String("bot-name", "-bot,[bot],deploy,guardian", "Well-known bot name users in the org, comma separated. Defaults to \"-bot,[bot],deploy,guardian\".")
+	intervalFlag                = flag.Duration("interval", 15*time.Minute, "How far to go backwards searching for actions to alert on")
+	maxReposClonedFlag          = flag.Int("max-repos-cloned-per-user", 5, "minimum repositories to see cloned before creating a user alert")
+	minDistinctReposClonedFlag  = flag.Int("min-distinct-repos-cloned", 0, "also require this many repositories cloned by full name, counting forks separately, before creating a user alert (0 for no minimum)")
+	maxCriticalReposClonedFlag  = flag.Int("max-critical-repos-cloned-per-user", 0, "minimum critical repositories to see cloned before creating a user alert, even below --max-repos-cloned-per-user (0 to disable)")
+	cloneIntervalFlag           = flag.Duration("clone-search-interval", 24*time.Hour, "How far to go backwards grouping git clone events by user; must be at least --interval")
+	cloneBurstWindowFlag        = flag.Duration("clone-burst-window", 0, "only alert when the repositories are cloned within a span this short, such as 10m (default any span within --clone-search-interval)")
+	cloneDensityFlag            = flag.Float64("clone-density", 0, "alert when a user clones at least this many distinct repos per minute in some burst, instead of on --max-repos-cloned-per-user (0 to count repos)")
+	resolveActorIDsFlag         = flag.Bool("resolve-actor-ids", false, "replace each entry's actor with the current login of its actor ID, cached in --state-dir for a day, so that renamed accounts are tracked as one")
+	dedupeCloneBurstsFlag       = flag.Bool("dedupe-clone-bursts", false, "only alert on a user's clone burst again if they clone more repos, as recorded in --state-dir")
+	criticalReposFlag           = flag.String("critical-repos", "", "critical repositories for more stringent checking, comma separated")
+	criticalReposFileFlag       = flag.String("critical-repos-file", "", "file of critical repositories, one per line; merged with --critical-repos")
+	criticalTopicFlag           = flag.String("critical-topic", "", "treat repositories with any of these topics as critical, comma separated; looked up once per run")
+	checkCriticalFlag           = flag.Bool("check-critical-repos", true, "warn at startup about critical repositories that do not exist in the org")
+	orgFlag                     = flag.String("org", "", "Github Organization to query")
+	emojiFlag                   = flag.Bool("emoji", false, "prefix alerts with an emoji for the action category")
+	issueRepoFlag               = flag.String("issue-repo", "", "GitHub repository (owner/repo) to file alerts as issues in")
+	cacheDirFlag                = flag.String("cache-dir", "", "directory to cache audit log entries in between runs, reducing API calls for overlapping windows")
+	stateDirFlag                = flag.String("state-dir", filepath.Join(os.TempDir(), "github-audit-alerter"), "directory to keep state between runs in, such as when notifications were last sent")
+	cacheMaxAgeFlag             = flag.Duration("cache-max-age", 48*time.Hour, "maximum age of cached audit log entries")
+	cacheMaxEntriesFlag         = flag.Int("cache-max-entries", 100000, "maximum number of cached audit log entries per kind")
+	notifyOnErrorFlag           = flag.Bool("notify-on-error", false, "send an alerter error notification when querying or notifying fails")
+	errorNotifyIntervalFlag     = flag.Duration("error-notify-interval", time.Hour, "minimum time between alerter error notifications")
+	firstRunFlag                = flag.Bool("first-run", false, "seed --state-dir from the events in --interval without sending any alerts, running the post-run hook, or exiting with --severity-exit-codes")
+	pauseFileFlag               = flag.String("pause-file", "", "while this file exists, still query and log alerts but do not send them")
+	includeTraceFlag            = flag.Bool("include-trace", false, "attach why each alert fired to its JSON in --post-run-hook, --dead-letter-file, and --output=sarif")
+	dailyReportToFlag           = flag.String("daily-report-to", "", "comma-separated email addresses to send a daily HTML summary of alerts by category and actor to")
+	smtpAddrFlag                = flag.String("smtp-addr", "", "SMTP server for --daily-report-to, as host:port")
+	smtpFromFlag                = flag.String("smtp-from", "", "sender address for --daily-report-to")
+	smtpUsernameFlag            = flag.String("smtp-username", "", "SMTP username, with the password from GH_AUDIT_SMTP_PASSWORD (default no authentication)")
+	smtpTLSFlag                 = flag.String("smtp-tls", "starttls", "how to secure the SMTP connection: starttls, tls, or none")
+	postRunHookFlag             = flag.String("post-run-hook", "", "shell command to run after each run, given a JSON summary of the run's alerts on stdin")
+	postRunHookTimeoutFlag      = flag.Duration("post-run-hook-timeout", time.Minute, "time after which --post-run-hook is killed")
+	postRunHookFailFlag         = flag.Bool("post-run-hook-fail", false, "fail the run, as for delivery failures, if --post-run-hook fails or times out")
+	githubHeaderFlag            = stringsVar("github-header", "extra \"Key: Value\" header to send with GitHub requests, may be repeated")
+	fieldsFlag                  = flag.String("fields", "", "comma separated alert message fields, in order, from: actor, action, location, visibility, user, name, explanation, timestamp, link, document, profile (default all but profile)")
+	opsgenieKeyFlag             = flag.String("opsgenie-api-key", "", "Opsgenie API key to create alerts with (defaults to $OPSGENIE_API_KEY)")
+	opsgenieURLFlag             = flag.String("opsgenie-url", "https://api.opsgenie.com", "Opsgenie API URL, such as https://api.eu.opsgenie.com")
+	circuitFailuresFlag         = flag.Int("circuit-failures", 0, "consecutive notify failures that open the circuit, dropping alerts for --circuit-cooldown (0 to disable)")
+	circuitCooldownFlag         = flag.Duration("circuit-cooldown", 5*time.Minute, "how long alerts are dropped once the circuit opens")
+	floodAlertsFlag             = flag.Int("flood-alerts", 0, "alerts within --flood-window after which further alerts are posted as one summary (0 to disable)")
+	floodWindowFlag             = flag.Duration("flood-window", time.Minute, "window for counting alerts towards --flood-alerts")
+	throttleMaxFlag             = flag.Int("throttle-max", 0, "maximum non-critical alerts per --throttle-window, across runs; the rest are posted as one summary (0 to disable)")
+	cooldownFlag                = stringsVar("cooldown", "action-regexp=duration to suppress repeats of an alert by the same actor on the same repo or org for, may be repeated; 0 never suppresses")
+	defaultCooldownFlag         = flag.Duration("default-cooldown", 0, "how long to suppress repeats of alerts for actions matching no --cooldown (0 to never suppress)")
+	throttleWindowFlag          = flag.Duration("throttle-window", time.Hour, "window for counting alerts towards --throttle-max")
+	phraseFlag                  = flag.String("phrase", "", "print entries within --interval matching this audit log search phrase, instead of running the detectors")
+	phraseNotifyFlag            = flag.Bool("phrase-notify", false, "also send notifications for entries matching --phrase")
+	ignoreCIDRsFlag             = flag.String("ignore-cidrs", "", "trusted networks, comma separated CIDRs or IPs, whose actors are not alerted on")
+	ignoreCIDRsURLFlag          = flag.String("ignore-cidrs-url", "", "URL of a published list of trusted networks, CIDRs or IPs separated by commas or whitespace, added to --ignore-cidrs")
+	ignoreCIDRsRefreshFlag      = flag.Duration("ignore-cidrs-refresh", time.Hour, "how often to fetch --ignore-cidrs-url again, rather than use the cached list")
+	redactFlag                  = stringsVar("redact", "regexp whose matches are replaced with *** in alerts, may be repeated")
+	alertSecurityDowngradeFlag  = flag.Bool("alert-security-downgrade", false, "alert when security features such as secret scanning or Dependabot alerts are disabled, even if ignored")
+	foundLogRateFlag            = flag.Int("found-log-rate", 0, "most \"found\" lines to log per second, summarizing the rest (0 for no limit)")
+	tokenCloneWindowFlag        = flag.Duration("token-clone-window", 0, "escalate excessive clones by users who created a token this long before (0 to disable)")
+	visibilityCloneWindowFlag   = flag.Duration("visibility-clone-window", 0, "escalate excessive clones of repos whose visibility changed this long before to critical (0 to disable)")
+	learnNewActionsFlag         = flag.Bool("learn-new-actions", false, "hold back alerts for actions that no ignore list or detector classifies until --learn-grace after they are first seen")
+	learnGraceFlag              = flag.Duration("learn-grace", 72*time.Hour, "how long to hold back alerts for newly seen actions")
+	maxCollaboratorsFlag        = flag.Int("max-outside-collaborators", 0, "alert when a user adds this many outside collaborators within --collaborator-burst-window (0 to disable)")
+	collaboratorBurstWindowFlag = flag.Duration("collaborator-burst-window", time.Hour, "window for counting outside collaborators towards --max-outside-collaborators")
+	maxAlertsPerRunFlag         = flag.Int("max-alerts-per-run", 0, "post at most this many non-critical alerts individually each run, then one message counting the rest by category (0 for no limit)")
+	summaryOnlyFlag             = flag.Bool("summary-only", false, "instead of notifying on each alert, post one message counting alerts by category")
+	coalesceActorsFlag          = flag.Bool("coalesce-actors", false, "merge the alerts of an actor who trips both web event and clone detectors in a run into one alert")
+	allowedCountriesFlag        = flag.String("allowed-countries", "", "comma separated country codes; alerts for actors elsewhere are prefixed with foreign-location")
+	blockedCountriesFlag        = flag.String("blocked-countries", "", "comma separated country codes; alerts for actors in them are prefixed with blocked-location and critical")
+	deadLetterFileFlag          = flag.String("dead-letter-file", "", "file to record alerts that no notifier delivered, as NDJSON")
+	deadLetterMaxBytesFlag      = flag.Int64("dead-letter-max-bytes", 10<<20, "size beyond which undelivered alerts are dropped instead of added to --dead-letter-file")
+	retryDeadLetterFlag         = flag.Bool("retry-dead-letter", false, "resend the alerts in --dead-letter-file before looking for new events")
+	auditIncludeFlag            = stringsVar("audit-include", "audit log include value to query for a detector, as events=web|git|all or clones=web|git|all (repeatable, default events=web and clones=git)")
+	includePublicClonesFlag     = flag.Bool("include-public-clones", false, "count clones of public repos towards --max-repos-cloned-per-user")
+	visibilityFlipsFlag         = flag.String("visibility-flips", "alert", "how to alert on repos whose visibility changes end where they started: alert, collapse, or suppress")
+	alertAppInstallsFlag        = flag.Bool("alert-app-installs", false, "alert when GitHub Apps are installed or granted new permissions, even if ignored")
+	alertForcePushesFlag        = flag.Bool("alert-force-pushes", false, "alert with a prefix when branch protection against force pushes is overridden, rejects a push, or is changed, even if ignored")
+	alertFeatureDisabledFlag    = flag.Bool("alert-feature-disabled", false, "alert with a prefix when Dependabot, the dependency graph, or GitHub Actions is disabled for a repo, even if ignored")
+	alertTeamAdminFlag          = flag.Bool("alert-team-admin", false, "alert with a prefix when a team is granted admin on a repo, even if ignored")
+	alertOrgSecretsFlag         = flag.Bool("alert-org-secrets", false, "alert with a prefix when organization-level Actions secrets or variables change, even if ignored")
+	alertDismissalsFlag         = flag.Bool("alert-dismissals", false, "alert with a prefix when Dependabot or secret scanning alerts are dismissed or resolved by hand, even if ignored")
+	alertAutoDismissalsFlag     = flag.Bool("alert-auto-dismissals", false, "with --alert-dismissals, also alert when Dependabot dismisses alerts by its own rules")
+	startupRetriesFlag          = flag.Int("startup-retries", 0, "times to retry when GITHUB_TOKEN is unset or rejected at startup, instead of exiting (0 to fail fast)")
+	startupRetryDelayFlag       = flag.Duration("startup-retry-delay", 10*time.Second, "time between --startup-retries")
+	userAgentFlag               = flag.String("user-agent", "github-audit-alerter/"+version, "User-Agent for GitHub API requests")
+	statsdAddrFlag              = flag.String("statsd-addr", "", "StatsD server to send run metrics to over UDP, as host:port")
+	statsdPrefixFlag            = flag.String("statsd-prefix", "github_audit_alerter.", "prefix for StatsD metric names")
+	statsdTagsFlag              = flag.String("statsd-tags", "", "comma separated key:value tags to add to every StatsD metric, in addition to org")
+	severityExitCodesFlag       = flag.Bool("severity-exit-codes", false, "exit with 10, 20, or 30 when the most severe alert found is medium, high, or critical")
+	checkPermissionsFlag        = flag.Bool("check-permissions", false, "check that the token can make the API calls the enabled detectors and sinks need, print the results, and exit")
+	printSchemaFlag             = flag.Bool("print-schema", false, "print a JSON Schema of alerts as they are written as JSON, such as to --post-run-hook, and exit")
+	dumpConfigFlag              = flag.Bool("dump-config", false, "print the effective value of every flag as JSON, with credentials redacted, and exit")
+	versionFlag                 = flag.Bool("version", false, "print the version and exit")
+	repeatOffenderRunsFlag      = flag.String("repeat-offender-runs", "", "comma separated consecutive run counts after which an actor's alerts are raised another severity level, for example 2,4")
+	maxAgeFlag                  = flag.Duration("max-age", 0, "ignore entries older than this, regardless of the query window (0 to disable)")
+	newRepoGraceFlag            = flag.Duration("new-repo-grace", 0, "ignore events on non-critical repos this long after they were created, such as 30m, unless an --alert-* flag surfaces them (0 to disable)")
+	enrichFlag                  = flag.String("enrich", "", "comma separated details to add to alerts, in order, from: user-name, profile-link, repo-fork-status")
+	extractFlag                 = stringsVar("extract", "field=regexp adding the regexp's capture groups in an audit entry field as alert details, named after named groups; may be repeated")
+	repoFilterFileFlag          = flag.String("repo-filter-file", "", "YAML file with allow and deny lists of repos to alert on web events for; deny wins")
+	alertWorkflowPermsFlag      = flag.Bool("alert-workflow-perms", false, "alert when GitHub Actions workflow permissions are escalated, even if ignored")
+	correlationBucketFlag       = flag.Duration("correlation-bucket", 0, "add a correlation ID shared by alerts for the same actor within each span this long (0 to disable)")
+	alertAuditAccessFlag        = flag.Bool("alert-audit-access", false, "alert with a prefix when the audit log is exported or its streaming changes, even if ignored")
+	timeFormatFlag              = flag.String("time-format", "", "Go time layout, or rfc3339, rfc1123, kitchen, datetime, or stamp, for alert timestamps")
+	timeZoneFlag                = flag.String("time-zone", "", "time zone for alert timestamps, such as America/New_York (default the host's)")
+	outputFlag                  = flag.String("output", "", "also write alerts to --output-file as cef or leef lines, or as one sarif log per run")
+	outputFileFlag              = flag.String("output-file", "", "file to write --output to, appending cef and leef, and replacing sarif (default stdout)")
+	outputVendorFlag            = flag.String("output-vendor", "Chainguard", "device vendor in --output headers, or SARIF tool organization")
+	outputProductFlag           = flag.String("output-product", "github-audit-alerter", "device product in --output headers, or SARIF tool name")
+	outputVersionFlag           = flag.String("output-version", version, "device version in --output headers, or SARIF tool version")
+	slackWebhookFlag            = stringsVar("slack-webhook", "Slack incoming webhook URL to post alerts to, may be repeated to post to several (default $GH_AUDIT_SLACK_WEBHOOK)")
+	slackChannelFlag            = flag.String("slack-channel", "", "Slack channel ID to post to with the Web API and $GH_AUDIT_SLACK_TOKEN, instead of the incoming webhook, when threading")
+	slackThreadWebhooksFlag     = flag.Bool("slack-thread-webhooks", false, "with --slack-thread-window, also post alerts to each --slack-webhook, unthreaded")
+	slackThreadWindowFlag       = flag.Duration("slack-thread-window", 0, "post alerts about an actor as replies to their first alert within this window (0 to not thread)")
+	gchatWebhookFlag            = flag.String("gchat-webhook-url", "", "Google Chat incoming webhook URL to post alerts to (default $GH_AUDIT_GCHAT_WEBHOOK)")
+	webhookSuccessFlag          = flag.String("webhook-success", "", "regexp that Slack and Google Chat webhook response bodies must match, or json:path[=value] for a JSON field they must have, to count as delivered (default any successful status)")
+	maxGrantsFlag               = flag.Int("max-grants-per-user", 0, "alert when a user is granted access to this many repos within --grant-burst-window (0 to disable)")
+	grantBurstWindowFlag        = flag.Duration("grant-burst-window", time.Hour, "window for counting repos towards --max-grants-per-user")
+	maxReposCreatedFlag         = flag.Int("max-repos-created", 0, "alert when a user creates this many repos within --repo-creation-burst-window (0 to disable)")
+	repoCreationBurstWindowFlag = flag.Duration("repo-creation-burst-window", time.Hour, "window for counting repos towards --max-repos-created")
+	repoSeverityFlag            = stringsVar("repo-severity", "repo=severity raising alerts about a repo, or glob of repos, to at least medium, high, or critical; may be repeated")
+	runbookFlag                 = stringsVar("runbook", "action-regexp=url of a runbook to link in matching alerts, may be repeated; the URL may contain {org}, {repo}, and {action}")
+	defaultRunbookFlag          = flag.String("default-runbook", "", "runbook URL to link in alerts that match no --runbook")
+	categoryFlag                = stringsVar("category", "action-regexp=category to report in alert JSON, may be repeated; checked before the built-in secrets, visibility, membership, and access categories")
+	phraseOrderFlag             = flag.String("phrase-order", "desc", "order of --phrase results: desc for newest first, or asc for oldest first")
+	notifyEmptyFlag             = flag.Bool("notify-empty", false, "send a notification when a run finds nothing to alert on, to show the alerter is running")
+	notifyEmptyIntervalFlag     = flag.Duration("notify-empty-interval", time.Hour, "minimum time between --notify-empty notifications")
+	botNameFlag                 = flag.String("bot-name", "-bot,[bot],deploy,guardian", "Well-known bot name users in the org, comma separated. Defaults to \"-bot,[bot],deploy,guardian\".")
+	botRegexpFlag               = stringsVar("bot-regexp", "regexp matching the whole login of a bot user, ignoring case, for bots not covered by --bot-name suffixes; may be repeated")
+	includeBotsFlag             = flag.Bool("include-bots", false, "alert on actors matching --bot-name or --bot-regexp too, prefixed with \"bot:\"")
 )
 
+// stringsFlag collects the values of a flag that may be repeated
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// stringsVar defines a repeatable string flag
+func stringsVar(name string, usage string) *stringsFlag {
+	f := &stringsFlag{}
+	flag.Var(f, name, usage)
+	return f
+}
+
+// headerTransport adds extra headers to every request
+type headerTransport struct {
+	headers http.Header
+	base    http.RoundTripper
+}
+
+// newGitHubClient returns a client authenticating with token and sending headers on every request
+func newGitHubClient(ctx context.Context, token string, headers http.Header) *github.Client {
+	tc := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
+	if len(headers) > 0 {
+		tc.Transport = headerTransport{headers: headers, base: tc.Transport}
+	}
+	c := github.NewClient(tc)
+	c.UserAgent = *userAgentFlag
+	return c
+}
+
+// startupClient waits for GITHUB_TOKEN to be set and accepted, checking up to retries more times,
+// delay apart. Orchestrators sometimes start the alerter before its token is populated.
+func startupClient(ctx context.Context, headers http.Header, retries int, delay time.Duration) (*github.Client, error) {
+	var err error
+	for i := 0; ; i++ {
+		token := os.Getenv("GITHUB_TOKEN")
+		if token == "" {
+			err = fmt.Errorf("GITHUB_TOKEN must be set")
+		} else {
+			c := newGitHubClient(ctx, token, headers)
+			// Checking rate limits does not count against them
+			if _, _, err = c.RateLimits(ctx); err == nil {
+				return c, nil
+			}
+		}
+		if i == retries {
+			return nil, err
+		}
+		log.Printf("GitHub client not ready, retrying in %s (%d/%d): %v", delay, i+1, retries, err)
+		time.Sleep(delay)
+	}
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, vs := range t.headers {
+		req.Header[k] = vs
+	}
+	return t.base.RoundTrip(req)
+}
+
+// parseHeaders parses "Key: Value" headers, refusing to override the token's Authorization header
+func parseHeaders(hs []string) (http.Header, error) {
+	h := http.Header{}
+	for _, kv := range hs {
+		k, v, ok := strings.Cut(kv, ":")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%q is not in \"Key: Value\" form", kv)
+		}
+		if strings.EqualFold(k, "Authorization") {
+			return nil, fmt.Errorf("the Authorization header is set from GITHUB_TOKEN")
+		}
+		h.Add(k, strings.TrimSpace(v))
+	}
+	return h, nil
+}
+
 func auditString(a *github.AuditEntry) string {
 	b, _ := json.Marshal(a)
 	return string(b)
 }
 
+// entriesScanned counts the audit entries returned by auditLog, for the run summary
+var entriesScanned int
+
+// auditLog returns audit entries of the given kind, newest first, back to since
 func auditLog(ctx context.Context, c *github.Client, kind string, since time.Time) ([]*github.AuditEntry, error) {
+	var as []*github.AuditEntry
+	var err error
+	if *cacheDirFlag != "" {
+		as, err = cachedAuditLog(ctx, c, kind, since)
+	} else {
+		as, err = fetchAuditLog(ctx, c, kind, "", since, "desc")
+	}
+	entriesScanned += len(as)
+	metrics.count("entries_scanned", len(as), "kind:"+kind)
+	return as, err
+}
+
+// auditIncludes are the values GitHub accepts for the audit log's include parameter
+var auditIncludes = []string{"all", "git", "web"}
+
+// parseAuditIncludes parses "detector=include" pairs for --audit-include into the include for each detector,
+// starting from web for events and git for clones
+func parseAuditIncludes(pairs []string) (map[string]string, error) {
+	includes := map[string]string{"events": "web", "clones": "git"}
+	for _, p := range pairs {
+		detector, include, ok := strings.Cut(p, "=")
+		if _, known := includes[detector]; !ok || !known {
+			return nil, fmt.Errorf("%q is not in detector=include form, with detector events or clones", p)
+		}
+		if !slices.Contains(auditIncludes, include) {
+			return nil, fmt.Errorf("%s: include must be one of %s, not %q", detector, strings.Join(auditIncludes, ", "), include)
+		}
+		includes[detector] = include
+	}
+	return includes, nil
+}
+
+// fetchAuditLog queries GitHub for audit entries matching an optional search phrase since a time.
+// In the default "desc" order, it stops once it passes since; in "asc" order, it skips entries until since.
+func fetchAuditLog(ctx context.Context, c *github.Client, kind string, phrase string, since time.Time, order string) ([]*github.AuditEntry, error) {
+	ascending := order == "asc"
 	opts := &github.GetAuditLogOptions{
 		Include: github.String(kind),
+		Order:   github.String(order),
 	}
+	// Let GitHub skip older entries too, unless the phrase already filters on time.
+	// Entries are still checked against since below, in case the filter is not applied.
+	if !strings.Contains(phrase, "created:") {
+		phrase = strings.TrimSpace(fmt.Sprintf("%s created:>=%s", phrase, since.UTC().Format(time.RFC3339)))
+	}
+	opts.Phrase = github.String(phrase)
 	opts.ListCursorOptions.PerPage = 100
 	as := []*github.AuditEntry{}
 
 	log.Printf("querying %q audit events since %s", kind, since)
-	logs, resp, err := c.Organizations.GetAuditLog(ctx, *orgFlag, opts)
+	logs, resp, err := getAuditLog(ctx, c, *orgFlag, opts)
 	if err != nil {
 		return as, err
 	}
 
 	for _, l := range logs {
-		as = append(as, l)
 		if l.GetTimestamp().Before(since) {
+			if ascending {
+				continue
+			}
 			return as, nil
 		}
+		as = append(as, l)
 	}
 
 	for resp.After != "" {
 		opts.ListCursorOptions.After = resp.After
-		logs, resp, err = c.Organizations.GetAuditLog(ctx, *orgFlag, opts)
+		logs, resp, err = getAuditLog(ctx, c, *orgFlag, opts)
 		time.Sleep(100 * time.Millisecond)
 
 		if err != nil {
@@ -156,10 +567,13 @@ func auditLog(ctx context.Context, c *github.Client, kind string, since time.Tim
 		}
 
 		for _, l := range logs {
-			as = append(as, l)
 			if l.GetTimestamp().Before(since) {
+				if ascending {
+					continue
+				}
 				return as, nil
 			}
+			as = append(as, l)
 		}
 
 		if len(as)%1000 == 0 {
@@ -171,247 +585,2465 @@ func auditLog(ctx context.Context, c *github.Client, kind string, since time.Tim
 }
 
 type Settings struct {
-	Since          time.Time
+	// Since is the start of the alert window: only events after it are alerted on
+	Since time.Time
+	// MaxClonesSince is the start of the clone grouping window, used to count the
+	// distinct repos cloned by each user. It must not be after Since.
 	MaxClonesSince time.Time
-	Org            string
-	BotNames       []string
+	// EventsInclude and ClonesInclude are the audit log include values queried for web events and clones
+	EventsInclude string
+	ClonesInclude string
+	Org           string
+	BotNames      []string
+	// BotPatterns match the whole login of bots whose names have no common suffix
+	BotPatterns []*regexp.Regexp
 
 	GlobalIgnoreActions      []string
 	NonCriticalIgnoreActions []string
-	CriticalRepos            []string
+	// CriticalRepos matches lowercase "org/repo" names, see normalizeRepos
+	CriticalRepos repoSet
 
 	MaxClonedRepos int
-}
+	// CloneBurstWindow, if set, requires MaxClonedRepos to be cloned within a span this short
+	CloneBurstWindow time.Duration
+	// RepoFilter, if set, limits which repos web events are alerted on
+	RepoFilter *repoFilter
+	// MaxAge, if set, drops entries older than this, whatever the query window
+	MaxAge time.Duration
+	// MaxCriticalClonedRepos, if set, is a lower threshold than MaxClonedRepos counting only critical repos
+	MaxCriticalClonedRepos int
+	// MinDistinctClonedRepos, if set, also requires this many repos by full name, so that forks count separately
+	MinDistinctClonedRepos int
+	// NewRepoGrace, if set, ignores events on non-critical repos this long after they were created
+	NewRepoGrace time.Duration
+	// MinCloneDensity, if set, trips the clone threshold on repos cloned per minute instead of MaxClonedRepos
+	MinCloneDensity float64
+	// IncludeBots alerts on bots like any other actor, prefixing their alerts with "bot:"
+	IncludeBots bool
+	// IncludePublicClones counts clones of public repos towards MaxClonedRepos
+	IncludePublicClones bool
+	// TokenCloneWindow, if set, escalates clones by an actor within this long after they created a token
+	TokenCloneWindow time.Duration
+	// VisibilityCloneWindow, if set, escalates clones of a repo within this long after its visibility changed
+	VisibilityCloneWindow time.Duration
+	// MaxOutsideCollaborators, if set, alerts on users adding this many outside collaborators
+	// within CollaboratorBurstWindow
+	MaxOutsideCollaborators int
+	CollaboratorBurstWindow time.Duration
+	// MaxGrantsPerUser, if set, alerts on users being granted access to this many repos within GrantBurstWindow
+	MaxGrantsPerUser int
+	GrantBurstWindow time.Duration
+	// MaxReposCreated, if set, alerts on users creating this many repos within RepoCreationBurstWindow
+	MaxReposCreated         int
+	RepoCreationBurstWindow time.Duration
+	// VisibilityFlips is how to alert on repos whose visibility changes cancel out: alert, collapse, or suppress
+	VisibilityFlips string
+	// CloneBursts, if set, records the clone bursts already alerted on, so that they are not alerted on again unless they grow
+	CloneBursts *cloneBursts
+	// LearnedActions, if set, holds back alerts for actions that no list classifies until their grace period ends
+	LearnedActions *learnedActions
 
-func webEvents(ctx context.Context, c *github.Client, s Settings) ([]*github.AuditEntry, error) {
-	log.Printf("looking for web events impacting %s since %s", s.Org, s.Since)
+	// Overrides surface otherwise ignored actions, see override
+	Overrides []*override
+	// IgnoreCIDRs are trusted networks whose actors' events are not alerted on
+	IgnoreCIDRs []netip.Prefix
+	// AllowedCountries and BlockedCountries are upper case country codes that actors are expected, or not, to be in
+	AllowedCountries map[string]bool
+	BlockedCountries map[string]bool
 
-	ig := []string{}
-	for _, i := range s.GlobalIgnoreActions {
-		ig = append(ig, fmt.Sprintf("^%s$", i))
-	}
-	globalIgnoreRe := regexp.MustCompile(strings.Join(ig, "|"))
+	// RepoSeverities raise the severity of alerts about particular repos
+	RepoSeverities []repoSeverity
+	// Runbooks link alerts to response guides by action
+	Runbooks []runbook
+	// Categories group alerts by action in their JSON, see categoryFor
+	Categories []actionCategory
 
-	ig = []string{}
-	for _, i := range s.NonCriticalIgnoreActions {
-		ig = append(ig, fmt.Sprintf("^%s$", i))
-	}
-	nonCriticalIgnoreRe := regexp.MustCompile(strings.Join(ig, "|"))
+	// Emoji prefixes alert messages with an emoji for the action
+	Emoji bool
+	// Fields are the msgFields included in alert messages, in order
+	Fields []string
+}
 
-	matches := []*github.AuditEntry{}
-	audit, err := auditLog(ctx, c, "web", s.Since)
+// readRepoFile reads repository names from path, one per line, skipping blank lines and # comments
+func readRepoFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
 	if err != nil {
-		return matches, err
+		return nil, err
 	}
 
-	critical := map[string]bool{}
-	for _, r := range s.CriticalRepos {
-		if strings.Contains(r, "/") {
-			critical[r] = true
+	repos := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
 			continue
 		}
-		critical[fmt.Sprintf("%s/%s", s.Org, r)] = true
+		repos = append(repos, line)
 	}
+	return repos, nil
+}
 
-	for _, a := range audit {
-		if globalIgnoreRe.MatchString(a.GetAction()) {
+// splitRepoIDs separates repositories given by numeric ID, as "id:123", from those given by name
+func splitRepoIDs(repos []string) ([]int64, []string, error) {
+	ids := []int64{}
+	names := []string{}
+	for _, r := range repos {
+		v, ok := strings.CutPrefix(strings.TrimSpace(r), "id:")
+		if !ok {
+			names = append(names, r)
 			continue
 		}
-		if !critical[a.GetRepo()] && nonCriticalIgnoreRe.MatchString(a.GetAction()) {
-			continue
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, nil, fmt.Errorf("%q is not a repository ID", r)
 		}
+		ids = append(ids, id)
+	}
+	return ids, names, nil
+}
 
-		if isBot(a.GetActor(), s.BotNames) {
+// repoNamesByID looks up the current "org/repo" name of each repository ID, in lowercase, so that
+// renamed repositories keep matching. IDs that cannot be looked up are returned with the error.
+func repoNamesByID(ctx context.Context, c *github.Client, ids []int64) ([]string, []int64, error) {
+	names := []string{}
+	failed := []int64{}
+	var errs []error
+	for _, id := range ids {
+		r, _, err := c.Repositories.GetByID(ctx, id)
+		if err != nil {
+			failed = append(failed, id)
+			errs = append(errs, fmt.Errorf("id:%d: %w", id, err))
 			continue
 		}
-
-		log.Printf("found: %s", auditString(a))
-		matches = append(matches, a)
+		names = append(names, strings.ToLower(r.GetFullName()))
 	}
+	return names, failed, errors.Join(errs...)
+}
 
-	return matches, nil
+// repoSet matches repositories by name, or by glob pattern such as "org/*-prod"
+type repoSet struct {
+	names    map[string]bool
+	patterns []string
 }
 
-func isBot(s string, botNames []string) bool {
-	for _, bots := range botNames {
-		if strings.HasSuffix(s, bots) {
+// has reports whether the set contains a repo, ignoring case
+func (rs repoSet) has(repo string) bool {
+	repo = strings.ToLower(repo)
+	if rs.names[repo] {
+		return true
+	}
+	for _, p := range rs.patterns {
+		if ok, _ := path.Match(p, repo); ok {
 			return true
 		}
 	}
 	return false
 }
 
-func cloneEvents(ctx context.Context, c *github.Client, s Settings) ([]*github.AuditEntry, error) {
-	log.Printf("looking for clone events impacting private repos since %s", s.MaxClonesSince)
-
-	matches := []*github.AuditEntry{}
-	audit, err := auditLog(ctx, c, "git", s.MaxClonesSince)
-	if err != nil {
-		return matches, err
-	}
-
-	cloneEvents := map[string][]*github.AuditEntry{}
-
-	for _, a := range audit {
-		if a.GetAction() != "git.clone" {
+// normalizeRepos trims, org-prefixes, and lowercases repository names and patterns, as GitHub names are case-insensitive
+func normalizeRepos(org string, repos []string) (repoSet, error) {
+	rs := repoSet{names: map[string]bool{}}
+	for _, r := range repos {
+		r = strings.TrimSpace(r)
+		if r == "" {
 			continue
 		}
-
-		if a.GetRepositoryPublic() {
-			continue
+		if !strings.Contains(r, "/") {
+			r = fmt.Sprintf("%s/%s", org, r)
 		}
+		r = strings.ToLower(r)
 
-		if isBot(a.GetActor(), s.BotNames) {
+		if !strings.ContainsAny(r, "*?[") {
+			rs.names[r] = true
 			continue
 		}
-
-		_, ok := cloneEvents[a.GetActor()]
-		if !ok {
-			cloneEvents[a.GetActor()] = []*github.AuditEntry{}
+		if _, err := path.Match(r, ""); err != nil {
+			return rs, fmt.Errorf("%q: %w", r, err)
 		}
+		rs.patterns = append(rs.patterns, r)
+	}
+	return rs, nil
+}
 
-		cloneEvents[a.GetActor()] = append(cloneEvents[a.GetActor()], a)
+// missingRepos returns the repositories within org that are not found in the org's repository list
+func missingRepos(ctx context.Context, c *github.Client, org string, repos map[string]bool) ([]string, error) {
+	opts := &github.RepositoryListByOrgOptions{}
+	opts.ListOptions.PerPage = 100
+	found := map[string]bool{}
+
+	for {
+		rs, resp, err := c.Repositories.ListByOrg(ctx, org, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rs {
+			found[strings.ToLower(r.GetFullName())] = true
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
 	}
 
-	log.Printf("finding excessive clones after %s", s.Since)
-	for u, events := range cloneEvents {
-		repos := map[string]bool{}
-		for _, e := range events {
-			// Go by the base-name so that we don't double-count forks
-			base := filepath.Base(e.GetRepository())
-			repos[base] = true
+	missing := []string{}
+	prefix := strings.ToLower(org) + "/"
+	for r := range repos {
+		if strings.HasPrefix(r, prefix) && !found[r] {
+			missing = append(missing, r)
 		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
 
-		log.Printf("%s has %d git clone events, affected repos: %v", u, len(events), repos)
+// reposWithTopics returns the lowercase full names of the org's repositories carrying any of topics
+func reposWithTopics(ctx context.Context, c *github.Client, org string, topics []string) ([]string, error) {
+	opts := &github.RepositoryListByOrgOptions{}
+	opts.ListOptions.PerPage = 100
+	found := []string{}
 
-		if len(repos) >= s.MaxClonedRepos {
-			seen := map[string]bool{}
-			for _, e := range events {
-				if e.GetTimestamp().Before(s.Since) {
-					log.Printf("ignoring excessive clone before %s: %s", s.Since, auditString(e))
-					continue
-				}
-				if !seen[e.GetRepo()] {
-					matches = append(matches, e)
-					log.Printf("found: %s", auditString(e))
+	for {
+		rs, resp, err := c.Repositories.ListByOrg(ctx, org, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rs {
+			for _, t := range r.Topics {
+				if slices.Contains(topics, strings.ToLower(t)) {
+					found = append(found, strings.ToLower(r.GetFullName()))
+					break
 				}
-				seen[e.GetRepo()] = true
 			}
 		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
 	}
+	return found, nil
+}
 
-	return matches, nil
+// validateWindows ensures that the clone grouping window covers the alert window
+func validateWindows(interval time.Duration, cloneInterval time.Duration) error {
+	if interval > cloneInterval {
+		return fmt.Errorf("--interval (%s) must not be larger than --clone-search-interval (%s)", interval, cloneInterval)
+	}
+	return nil
 }
 
-func main() {
-	flag.Parse()
-	ghToken := os.Getenv("GITHUB_TOKEN")
+// override surfaces actions under a prefix, even if they would otherwise be ignored
+type override struct {
+	prefix string
+	re     *regexp.Regexp
+	// match, if set, further restricts which entries with a matching action are surfaced
+	match func(a *github.AuditEntry) bool
+}
 
-	if ghToken == "" {
-		log.Fatalf("GITHUB_TOKEN must be set")
+// newOverride returns an override for action regexps, written as in the ignore lists
+func newOverride(prefix string, actions []string, match func(a *github.AuditEntry) bool) *override {
+	return &override{prefix: prefix, re: actionRegexp(actions), match: match}
+}
+
+// actionRegexp matches actions against any of the patterns in full, and matches nothing without patterns.
+// Case is ignored, as GitHub has changed the case of action names before.
+func actionRegexp(patterns []string) *regexp.Regexp {
+	if len(patterns) == 0 {
+		return regexp.MustCompile(`[^\x00-\x{10FFFF}]`)
+	}
+	res := []string{}
+	for _, p := range patterns {
+		res = append(res, fmt.Sprintf("^%s$", p))
 	}
+	return regexp.MustCompile("(?i)" + strings.Join(res, "|"))
+}
 
-	if *orgFlag == "" {
-		log.Fatalf("--org must be passed")
+// workflowPermsEscalation reports whether a workflow permission change grants more access: the default
+// permissions becoming write, or workflows becoming able to approve pull requests, which entries record as
+// active after the change and active_was before it. Entries that do not say are not surfaced.
+func workflowPermsEscalation(a *github.AuditEntry) bool {
+	if strings.HasSuffix(a.GetAction(), ".set_workflow_permission_can_approve_pr") {
+		return a.GetActive() && !a.GetActiveWas()
 	}
+	return a.GetPermission() == "write" && a.GetOldPermission() != "write"
+}
 
-	ctx := context.Background()
-	tc := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: ghToken}))
-	c := github.NewClient(tc)
+// teamAdminGrant reports whether a team repo permission change grants admin, going by the entry's
+// permission; entries without one are not surfaced
+func teamAdminGrant(a *github.AuditEntry) bool {
+	return strings.EqualFold(a.GetPermission(), "admin") && !strings.EqualFold(a.GetOldPermission(), "admin")
+}
 
-	s := Settings{
-		Org:                      *orgFlag,
-		Since:                    time.Now().Add(-1 * *intervalFlag),
-		BotNames:                 strings.Split(*botNameFlag, ","),
-		GlobalIgnoreActions:      universalIgnore,
-		NonCriticalIgnoreActions: nonCriticalIgnore,
-		MaxClonedRepos:           *maxReposClonedFlag,
-		MaxClonesSince:           time.Now().Add(-1 * *cloneIntervalFlag),
-		CriticalRepos:            strings.Split(*criticalReposFlag, ","),
-	}
+// featureDisabled reports whether a feature toggle turns the feature off, going by the verb of its action,
+// such as "disable" in "repository_dependency_graph.disable" or "disabled" in "repo.actions_disabled"
+func featureDisabled(a *github.AuditEntry) bool {
+	_, verb, _ := strings.Cut(strings.ToLower(a.GetAction()), ".")
+	return verb == "disable" || strings.HasSuffix(verb, "disabled")
+}
 
-	wes, err := webEvents(ctx, c, s)
-	if err != nil {
-		log.Panicf("web events: %v", err)
+// matchOverride returns the first override that applies to an entry, if any
+func matchOverride(a *github.AuditEntry, overrides []*override) *override {
+	for _, o := range overrides {
+		if !o.re.MatchString(a.GetAction()) {
+			continue
+		}
+		if o.match != nil && !o.match(a) {
+			continue
+		}
+		return o
 	}
-	postFailures := 0
-	webhook := os.Getenv("GH_AUDIT_SLACK_WEBHOOK")
+	return nil
+}
 
-	for _, e := range wes {
-		if err := notify(webhook, auditMsg(e)); err != nil {
-			postFailures++
-			log.Printf("notify failed: %v", err)
+// repoCreations returns when each repo was created, by lowercase "org/repo", among the audit entries since the given time
+func repoCreations(audit []*github.AuditEntry, since time.Time) map[string]time.Time {
+	created := map[string]time.Time{}
+	for _, a := range audit {
+		if a.GetAction() == "repo.create" && !a.GetTimestamp().Before(since) {
+			created[strings.ToLower(a.GetRepo())] = a.GetTimestamp().Time
 		}
 	}
+	return created
+}
 
-	ces, err := cloneEvents(ctx, c, s)
-	if err != nil {
-		log.Panicf("clone events: %v", err)
-	}
-	for _, e := range ces {
-		if err := notify(webhook, fmt.Sprintf("excessive clone[>=%d]: %s", s.MaxClonedRepos, auditMsg(e))); err != nil {
-			postFailures++
-			log.Printf("notify failed: %v", err)
-		}
+// inRepoGrace reports whether an entry is about a repo created within grace before it, other than its creation
+func inRepoGrace(a *github.AuditEntry, created map[string]time.Time, grace time.Duration) bool {
+	t, ok := created[strings.ToLower(a.GetRepo())]
+	if !ok || a.GetAction() == "repo.create" {
+		return false
 	}
+	at := a.GetTimestamp().Time
+	return !at.Before(t) && at.Sub(t) <= grace
+}
+
+// alwaysAlert reports whether an entry's action is one of alwaysAlertActions
+func alwaysAlert(a *github.AuditEntry) bool {
+	return a != nil && slices.Contains(alwaysAlertActions, strings.ToLower(a.GetAction()))
+}
 
-	if postFailures > 0 {
-		log.Panicf("%d post failures: %v", postFailures, err)
+// webLogSince returns how far back the web events, burst, and clone precursor detectors need the audit log,
+// so that it is only fetched once for them all
+func webLogSince(s Settings) time.Time {
+	since := s.Since
+	for _, b := range []struct {
+		enabled bool
+		start   time.Time
+	}{
+		{s.NewRepoGrace > 0, s.Since.Add(-s.NewRepoGrace)},
+		{s.MaxOutsideCollaborators > 0, s.Since.Add(-s.CollaboratorBurstWindow)},
+		{s.MaxGrantsPerUser > 0, s.Since.Add(-s.GrantBurstWindow)},
+		{s.MaxReposCreated > 0, s.Since.Add(-s.RepoCreationBurstWindow)},
+		{s.TokenCloneWindow > 0, s.MaxClonesSince.Add(-s.TokenCloneWindow)},
+		{s.VisibilityCloneWindow > 0, s.MaxClonesSince.Add(-s.VisibilityCloneWindow)},
+	} {
+		if b.enabled && b.start.Before(since) {
+			since = b.start
+		}
 	}
+	return since
 }
 
-func auditMsg(a *github.AuditEntry) string {
-	var sb strings.Builder
-	repo := a.GetRepo()
-	if repo == "" {
-		repo = a.GetRepository()
+// webEvents returns the entries of the web audit log, fetched back to webLogSince, that are alerted on
+func webEvents(audit []*github.AuditEntry, s Settings) []*github.AuditEntry {
+	log.Printf("looking for web events impacting %s since %s", s.Org, s.Since)
+
+	globalIgnoreRe := actionRegexp(s.GlobalIgnoreActions)
+	nonCriticalIgnoreRe := actionRegexp(s.NonCriticalIgnoreActions)
+	classifiedRe := actionRegexp(classifiedActions(s))
+
+	matches := []*github.AuditEntry{}
+	created := map[string]time.Time{}
+	if s.NewRepoGrace > 0 {
+		created = repoCreations(audit, s.Since.Add(-s.NewRepoGrace))
 	}
 
-	location := a.GetOrg()
-	if repo != "" {
-		if strings.Contains(repo, "/") {
-			location = repo
-		} else {
-			location = fmt.Sprintf("%s/%s", a.GetOrg(), repo)
+	for _, a := range audit {
+		if a.GetTimestamp().Before(s.Since) {
+			continue
+		}
+
+		// Git events are only returned with --audit-include=events=all, and are left to the clone detector
+		if strings.HasPrefix(a.GetAction(), "git.") {
+			continue
+		}
+
+		if tooOld(a, s.MaxAge) {
+			continue
+		}
+
+		if alwaysAlert(a) {
+			foundLog.Printf("found: %s", auditString(a))
+			matches = append(matches, a)
+			continue
+		}
+
+		if s.RepoFilter.skip(a.GetRepo()) {
+			continue
+		}
+
+		o := matchOverride(a, s.Overrides)
+		if o == nil {
+			if globalIgnoreRe.MatchString(a.GetAction()) {
+				continue
+			}
+			if !s.CriticalRepos.has(a.GetRepo()) && nonCriticalIgnoreRe.MatchString(a.GetAction()) {
+				continue
+			}
+		}
+
+		// Only medium severity events are held back; see alertSeverity and --repo-severity
+		if o == nil && !s.CriticalRepos.has(a.GetRepo()) && severityRank(repoSeverityFor(a, s.RepoSeverities)) < severityRank(severityHigh) &&
+			inRepoGrace(a, created, s.NewRepoGrace) {
+			log.Printf("ignoring %s within %s of %s being created", a.GetAction(), s.NewRepoGrace, a.GetRepo())
+			continue
+		}
+
+		if !s.IncludeBots && isBot(actorName(a), s.BotNames, s.BotPatterns) {
+			continue
 		}
-	}
 
-	sb.WriteString(fmt.Sprintf("%s: *%s* on *%s*", a.GetActor(), a.GetAction(), location))
+		if trustedIP(a.GetActorIP(), s.IgnoreCIDRs) {
+			log.Printf("ignoring %s by %s from trusted IP %s", a.GetAction(), actorName(a), a.GetActorIP())
+			continue
+		}
+
+		// Entries on critical repos always alert, so only their actions elsewhere are learned
+		if s.LearnedActions != nil && o == nil && !s.CriticalRepos.has(a.GetRepo()) && !classifiedRe.MatchString(a.GetAction()) &&
+			s.LearnedActions.suppress(a.GetAction(), time.Now()) {
+			log.Printf("ignoring newly seen action within its grace period: %s", auditString(a))
+			continue
+		}
 
-	if a.GetPreviousVisibility() != "" {
-		sb.WriteString(fmt.Sprintf(" visibility: %s->%s", a.GetPreviousVisibility(), a.GetVisibility()))
+		foundLog.Printf("found: %s", auditString(a))
+		matches = append(matches, a)
 	}
 
-	if a.GetUser() != "" {
-		sb.WriteString(fmt.Sprintf(" user: %q", a.GetUser()))
+	sortEntries(matches)
+	if s.VisibilityFlips != "" && s.VisibilityFlips != "alert" {
+		matches = collapseVisibilityFlips(matches, s.VisibilityFlips)
 	}
+	return matches
+}
+
+// visibilityFlipModes are the accepted values of --visibility-flips
+var visibilityFlipModes = map[string]bool{"alert": true, "collapse": true, "suppress": true}
 
-	if a.GetName() != "" {
-		sb.WriteString(fmt.Sprintf(" name: %q", a.GetName()))
+// collapseVisibilityFlips handles repos whose visibility changed more than once but ended where it
+// started: "collapse" keeps only the last change, and "suppress" drops them all. Entries must be in time order.
+func collapseVisibilityFlips(es []*github.AuditEntry, mode string) []*github.AuditEntry {
+	flips := map[string][]*github.AuditEntry{}
+	for _, e := range es {
+		if e.GetPreviousVisibility() != "" {
+			repo := strings.ToLower(e.GetRepo())
+			flips[repo] = append(flips[repo], e)
+		}
 	}
 
-	if a.GetExplanation() != "" {
-		sb.WriteString(fmt.Sprintf(" explanation: %q", a.GetExplanation()))
+	drop := map[*github.AuditEntry]bool{}
+	for repo, changes := range flips {
+		first, last := changes[0], changes[len(changes)-1]
+		if len(changes) < 2 || first.GetPreviousVisibility() != last.GetVisibility() {
+			continue
+		}
+		log.Printf("%s changed visibility %d times, ending %s as it started", repo, len(changes), last.GetVisibility())
+		for _, e := range changes {
+			if mode == "collapse" && e == last {
+				continue
+			}
+			drop[e] = true
+		}
 	}
 
-	ts := a.GetCreatedAt()
-	if ts.IsZero() {
-		ts = a.GetTimestamp()
+	kept := []*github.AuditEntry{}
+	for _, e := range es {
+		if drop[e] {
+			log.Printf("ignoring net zero visibility change: %s", auditString(e))
+			continue
+		}
+		kept = append(kept, e)
 	}
+	return kept
+}
 
-	sb.WriteString(fmt.Sprintf(": %s", ts))
+// phraseInclude is the audit log include --phrase searches, covering both web and git events
+const phraseInclude = "all"
 
-	u := url.URL{
-		Scheme: "https",
-		Host:   "github.com",
-		Path:   fmt.Sprintf("/organizations/%s/settings/audit-log", a.GetOrg()),
+// phraseEvents returns entries within the alert window that match a raw audit log search phrase
+func phraseEvents(ctx context.Context, c *github.Client, s Settings, phrase string) ([]*github.AuditEntry, error) {
+	log.Printf("searching %s for %q since %s", s.Org, phrase, s.Since)
+
+	matches := []*github.AuditEntry{}
+	audit, err := fetchAuditLog(ctx, c, phraseInclude, phrase, s.Since, *phraseOrderFlag)
+	entriesScanned += len(audit)
+	metrics.count("entries_scanned", len(audit), "kind:"+phraseInclude)
+	if err != nil {
+		return matches, err
 	}
-	q := u.Query()
-	q.Set("q", fmt.Sprintf("action:%s actor:%s", a.GetAction(), a.GetActor()))
-	u.RawQuery = q.Encode()
 
-	sb.WriteString(fmt.Sprintf(" [<%s|logs>]", u.String()))
-	return sb.String()
+	for _, a := range audit {
+		if a.GetTimestamp().Before(s.Since) {
+			continue
+		}
+		matches = append(matches, a)
+	}
+	return matches, nil
+}
+
+// parseCountries parses comma separated ISO 3166 country codes
+func parseCountries(list string) (map[string]bool, error) {
+	countries := map[string]bool{}
+	for _, c := range strings.Split(list, ",") {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if len(c) != 2 {
+			return nil, fmt.Errorf("%q is not a two letter country code", c)
+		}
+		countries[c] = true
+	}
+	return countries, nil
+}
+
+// locationPrefix flags entries by actors in --blocked-countries, or outside --allowed-countries.
+// Entries without a country are not flagged.
+func locationPrefix(a *github.AuditEntry, s Settings) string {
+	cc := strings.ToUpper(a.GetActorLocation().GetCountryCode())
+	switch {
+	case cc == "":
+		return ""
+	case s.BlockedCountries[cc]:
+		return "blocked-location"
+	case len(s.AllowedCountries) > 0 && !s.AllowedCountries[cc]:
+		return "foreign-location"
+	default:
+		return ""
+	}
+}
+
+// parseCIDRs parses comma separated CIDRs, treating bare IPs as single-address ranges
+func parseCIDRs(list string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, c := range strings.Split(list, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if !strings.Contains(c, "/") {
+			ip, err := netip.ParseAddr(c)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// trustedIP reports whether ip is within any of the trusted prefixes; entries without an IP are never trusted
+func trustedIP(ip string, prefixes []netip.Prefix) bool {
+	if ip == "" || len(prefixes) == 0 {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// tooOld reports whether an entry is older than maxAge, such as one GitHub reprocessed long after the fact
+func tooOld(a *github.AuditEntry, maxAge time.Duration) bool {
+	if maxAge <= 0 || time.Since(a.GetTimestamp().Time) <= maxAge {
+		return false
+	}
+	log.Printf("ignoring entry older than %s: %s", maxAge, auditString(a))
+	return true
+}
+
+// deletedActor stands in for the actor of entries whose login is empty, usually because the account was deleted
+const deletedActor = "<deleted-user>"
+
+// actorName returns the entry's actor login, or deletedActor if it is empty
+func actorName(a *github.AuditEntry) string {
+	if a.GetActor() == "" {
+		return deletedActor
+	}
+	return a.GetActor()
+}
+
+// isBot reports whether s ends with any of botNames, or matches any of botPatterns. Empty names never match.
+func isBot(s string, botNames []string, botPatterns []*regexp.Regexp) bool {
+	for _, bots := range botNames {
+		if bots != "" && strings.HasSuffix(s, bots) {
+			return true
+		}
+	}
+	for _, re := range botPatterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBotPatterns compiles regexps that must match a login in full, ignoring case like GitHub does
+func parseBotPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := []*regexp.Regexp{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(fmt.Sprintf("(?i)^(?:%s)$", p))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func cloneEvents(ctx context.Context, c *github.Client, s Settings) ([]*github.AuditEntry, error) {
+	visibility := "private"
+	if s.IncludePublicClones {
+		visibility = "all"
+	}
+	log.Printf("looking for clone events impacting %s repos since %s", visibility, s.MaxClonesSince)
+
+	matches := []*github.AuditEntry{}
+	audit, err := auditLog(ctx, c, s.ClonesInclude, s.MaxClonesSince)
+	if err != nil {
+		return matches, err
+	}
+
+	cloneEvents := map[string][]*github.AuditEntry{}
+
+	for _, a := range audit {
+		if a.GetAction() != "git.clone" {
+			continue
+		}
+
+		if tooOld(a, s.MaxAge) {
+			continue
+		}
+
+		if a.GetRepositoryPublic() && !s.IncludePublicClones {
+			continue
+		}
+
+		if !s.IncludeBots && isBot(actorName(a), s.BotNames, s.BotPatterns) {
+			continue
+		}
+
+		if trustedIP(a.GetActorIP(), s.IgnoreCIDRs) {
+			log.Printf("ignoring %s by %s from trusted IP %s", a.GetAction(), actorName(a), a.GetActorIP())
+			continue
+		}
+
+		_, ok := cloneEvents[actorName(a)]
+		if !ok {
+			cloneEvents[actorName(a)] = []*github.AuditEntry{}
+		}
+
+		cloneEvents[actorName(a)] = append(cloneEvents[actorName(a)], a)
+	}
+
+	log.Printf("finding excessive clones after %s", s.Since)
+	for _, u := range sortedKeys(cloneEvents) {
+		events := cloneEvents[u]
+		repos := map[string]bool{}
+		for _, e := range events {
+			// Go by the base-name so that we don't double-count forks
+			base := filepath.Base(e.GetRepository())
+			repos[base] = true
+		}
+
+		log.Printf("%s has %d git clone events, affected repos: %v", u, len(events), repos)
+
+		count := len(repos)
+		if s.CloneBurstWindow > 0 {
+			count = maxReposInWindow(events, s.CloneBurstWindow)
+			log.Printf("%s cloned at most %d repos within %s", u, count, s.CloneBurstWindow)
+		}
+
+		tripped := count >= s.MaxClonedRepos
+		if s.MinCloneDensity > 0 {
+			density := cloneDensity(firstClones(events))
+			log.Printf("%s cloned %.2f repos per minute", u, density)
+			tripped = density >= s.MinCloneDensity
+		}
+
+		if s.MaxCriticalClonedRepos > 0 && !tripped {
+			critical := []*github.AuditEntry{}
+			criticalRepos := map[string]bool{}
+			for _, e := range events {
+				if s.CriticalRepos.has(auditLocation(e)) {
+					critical = append(critical, e)
+					criticalRepos[filepath.Base(e.GetRepository())] = true
+				}
+			}
+			count := len(criticalRepos)
+			if s.CloneBurstWindow > 0 {
+				count = maxReposInWindow(critical, s.CloneBurstWindow)
+			}
+			log.Printf("%s cloned %d critical repos", u, count)
+			tripped = count >= s.MaxCriticalClonedRepos
+		}
+
+		if s.MinDistinctClonedRepos > 0 && tripped {
+			distinct := map[string]bool{}
+			for _, e := range events {
+				distinct[strings.ToLower(e.GetRepository())] = true
+			}
+			if len(distinct) < s.MinDistinctClonedRepos {
+				log.Printf("%s cloned only %d distinct repos, below --min-distinct-repos-cloned", u, len(distinct))
+				tripped = false
+			}
+		}
+
+		// A burst is identified by its user and first clone, so that overlapping runs can tell it apart from a new one
+		start := events[0].GetTimestamp().Time
+		for _, e := range events {
+			if e.GetTimestamp().Before(start) {
+				start = e.GetTimestamp().Time
+			}
+		}
+		if tripped && s.CloneBursts.alerted(u, start, len(repos)) {
+			log.Printf("already alerted on %s's burst of %d repos since %s", u, len(repos), start.Format(time.RFC3339))
+			tripped = false
+		}
+
+		if tripped {
+			seen := map[string]bool{}
+			for _, e := range events {
+				if e.GetTimestamp().Before(s.Since) {
+					log.Printf("ignoring excessive clone before %s: %s", s.Since, auditString(e))
+					continue
+				}
+				if !seen[e.GetRepo()] {
+					matches = append(matches, e)
+					foundLog.Printf("found: %s", auditString(e))
+				}
+				seen[e.GetRepo()] = true
+			}
+			if len(seen) > 0 {
+				s.CloneBursts.record(u, start, len(repos))
+			}
+		}
+	}
+
+	sortEntries(matches)
+	return matches, nil
+}
+
+// sortEntries orders entries by timestamp, then actor, then action, so that alerts are sent in a stable order
+func sortEntries(es []*github.AuditEntry) {
+	sort.SliceStable(es, func(i, j int) bool {
+		a, b := es[i], es[j]
+		if !a.GetTimestamp().Equal(b.GetTimestamp()) {
+			return a.GetTimestamp().Before(b.GetTimestamp().Time)
+		}
+		if a.GetActor() != b.GetActor() {
+			return a.GetActor() < b.GetActor()
+		}
+		return a.GetAction() < b.GetAction()
+	})
+}
+
+// sortedKeys returns the keys of entries grouped by actor, in order
+func sortedKeys(m map[string][]*github.AuditEntry) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// firstClones returns when each distinct repo was first cloned among the events, in time order
+func firstClones(events []*github.AuditEntry) []time.Time {
+	first := map[string]time.Time{}
+	for _, e := range events {
+		base := filepath.Base(e.GetRepository())
+		if t, ok := first[base]; !ok || e.GetTimestamp().Before(t) {
+			first[base] = e.GetTimestamp().Time
+		}
+	}
+
+	times := []time.Time{}
+	for _, t := range first {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+// cloneDensity scores sorted clone times by their densest run, in repos per minute. Each run of two or more
+// clones is scored over the span from its first to its last, counted as at least a minute, so a quick burst
+// stands out even among slower clones. Fewer than two clones are not a burst, and score 0.
+func cloneDensity(times []time.Time) float64 {
+	best := 0.0
+	for i := range times {
+		for j := i + 1; j < len(times); j++ {
+			span := max(times[j].Sub(times[i]), time.Minute)
+			best = max(best, float64(j-i+1)/span.Minutes())
+		}
+	}
+	return best
+}
+
+// maxReposInWindow returns the most distinct repos cloned within any window-long span of the events
+func maxReposInWindow(events []*github.AuditEntry, window time.Duration) int {
+	return maxDistinctInWindow(events, window, func(e *github.AuditEntry) string {
+		return filepath.Base(e.GetRepository())
+	})
+}
+
+// maxDistinctInWindow returns the most distinct keys among the events within any window-long span
+func maxDistinctInWindow(events []*github.AuditEntry, window time.Duration, key func(*github.AuditEntry) string) int {
+	sorted := append([]*github.AuditEntry{}, events...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetTimestamp().Before(sorted[j].GetTimestamp().Time)
+	})
+
+	most := 0
+	counts := map[string]int{}
+	start := 0
+	for _, e := range sorted {
+		counts[key(e)]++
+		for e.GetTimestamp().Sub(sorted[start].GetTimestamp().Time) > window {
+			k := key(sorted[start])
+			counts[k]--
+			if counts[k] == 0 {
+				delete(counts, k)
+			}
+			start++
+		}
+		if len(counts) > most {
+			most = len(counts)
+		}
+	}
+	return most
+}
+
+// burst detects many distinct things, such as repos or users, being affected within a short span
+type burst struct {
+	// what is counted, for logging
+	what    string
+	actions map[string]bool
+	// group returns who an entry is attributed to, and key what it affected
+	group  func(*github.AuditEntry) string
+	key    func(*github.AuditEntry) string
+	max    int
+	window time.Duration
+}
+
+// burstEvents returns entries within the alert window in groups that reached b.max distinct keys
+// within some b.window long span, among the web audit log fetched back to webLogSince
+func burstEvents(audit []*github.AuditEntry, s Settings, b burst) []*github.AuditEntry {
+	since := s.Since.Add(-b.window)
+	log.Printf("looking for %s since %s", b.what, since)
+
+	matches := []*github.AuditEntry{}
+	groups := map[string][]*github.AuditEntry{}
+	for _, a := range audit {
+		if !b.actions[a.GetAction()] || a.GetTimestamp().Before(since) {
+			continue
+		}
+
+		if tooOld(a, s.MaxAge) {
+			continue
+		}
+
+		if !s.IncludeBots && isBot(actorName(a), s.BotNames, s.BotPatterns) {
+			continue
+		}
+
+		if trustedIP(a.GetActorIP(), s.IgnoreCIDRs) {
+			log.Printf("ignoring %s by %s from trusted IP %s", a.GetAction(), actorName(a), a.GetActorIP())
+			continue
+		}
+
+		g := b.group(a)
+		if g == "" {
+			continue
+		}
+		groups[g] = append(groups[g], a)
+	}
+
+	for _, g := range sortedKeys(groups) {
+		events := groups[g]
+		count := maxDistinctInWindow(events, b.window, b.key)
+		log.Printf("%s: at most %d %s within %s", g, count, b.what, b.window)
+		if count < b.max {
+			continue
+		}
+
+		for _, e := range events {
+			if e.GetTimestamp().Before(s.Since) {
+				continue
+			}
+			matches = append(matches, e)
+			foundLog.Printf("found: %s", auditString(e))
+		}
+	}
+
+	sortEntries(matches)
+	return matches
+}
+
+// collaboratorBurst finds users adding at least s.MaxOutsideCollaborators within s.CollaboratorBurstWindow
+func collaboratorBurst(s Settings) burst {
+	return burst{
+		what:    "outside collaborator additions",
+		actions: map[string]bool{"org.add_outside_collaborator": true},
+		group:   actorName,
+		key:     (*github.AuditEntry).GetUser,
+		max:     s.MaxOutsideCollaborators,
+		window:  s.CollaboratorBurstWindow,
+	}
+}
+
+// grantBurst finds users being granted access to at least s.MaxGrantsPerUser repos, or the org,
+// within s.GrantBurstWindow, whoever granted it
+func grantBurst(s Settings) burst {
+	return burst{
+		what:    "access grants",
+		actions: map[string]bool{"org.add_member": true, "repo.add_member": true, "repo.update_member": true},
+		group:   (*github.AuditEntry).GetUser,
+		key:     auditLocation,
+		max:     s.MaxGrantsPerUser,
+		window:  s.GrantBurstWindow,
+	}
+}
+
+// repoCreationBurst finds users creating at least s.MaxReposCreated repos within s.RepoCreationBurstWindow
+func repoCreationBurst(s Settings) burst {
+	return burst{
+		what:    "repo creations",
+		actions: map[string]bool{"repo.create": true},
+		group:   actorName,
+		key:     auditLocation,
+		max:     s.MaxReposCreated,
+		window:  s.RepoCreationBurstWindow,
+	}
+}
+
+// tokenCreationActions are the actions that give a user a new token for the organization
+var tokenCreationActions = map[string]bool{
+	"personal_access_token.request_created": true,
+	"personal_access_token.access_granted":  true,
+}
+
+// tokenCreations returns when each user was last given a token, since the clone window minus s.TokenCloneWindow
+func tokenCreations(audit []*github.AuditEntry, s Settings) map[string][]time.Time {
+	since := s.MaxClonesSince.Add(-s.TokenCloneWindow)
+	log.Printf("looking for token creation events since %s", since)
+
+	created := map[string][]time.Time{}
+	for _, a := range audit {
+		if !tokenCreationActions[a.GetAction()] || a.GetTimestamp().Before(since) {
+			continue
+		}
+		// Grants are made by an admin on behalf of the token owner
+		owner := a.GetUser()
+		if owner == "" {
+			owner = a.GetActor()
+		}
+		created[owner] = append(created[owner], a.GetTimestamp().Time)
+	}
+	return created
+}
+
+// tokenPrecursor returns when the actor of a clone created a token within window before it, if they did
+func tokenPrecursor(e *github.AuditEntry, created map[string][]time.Time, window time.Duration) (time.Time, bool) {
+	cloned := e.GetTimestamp().Time
+	for _, t := range created[e.GetActor()] {
+		if !t.After(cloned) && cloned.Sub(t) <= window {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// visibilityChanges returns when each repo's visibility changed, since the clone window minus s.VisibilityCloneWindow
+func visibilityChanges(audit []*github.AuditEntry, s Settings) map[string][]time.Time {
+	since := s.MaxClonesSince.Add(-s.VisibilityCloneWindow)
+	log.Printf("looking for visibility change events since %s", since)
+
+	changed := map[string][]time.Time{}
+	for _, a := range audit {
+		if a.GetAction() != "repo.access" || a.GetTimestamp().Before(since) {
+			continue
+		}
+		repo := strings.ToLower(a.GetRepo())
+		changed[repo] = append(changed[repo], a.GetTimestamp().Time)
+	}
+	return changed
+}
+
+// visibilityPrecursor returns when the repo of a clone changed visibility within window before it, if it did
+func visibilityPrecursor(e *github.AuditEntry, changed map[string][]time.Time, window time.Duration) (time.Time, bool) {
+	cloned := e.GetTimestamp().Time
+	for _, t := range changed[strings.ToLower(e.GetRepo())] {
+		if !t.After(cloned) && cloned.Sub(t) <= window {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// secretEnv are the environment variables holding credentials, reported by --dump-config only as set or not
+var secretEnv = []string{"GITHUB_TOKEN", "GH_AUDIT_SLACK_WEBHOOK", "GH_AUDIT_SLACK_TOKEN", "OPSGENIE_API_KEY", "GH_AUDIT_GCHAT_WEBHOOK", "GH_AUDIT_SMTP_PASSWORD"}
+
+// secretFlags are the flags whose values may hold credentials, redacted by --dump-config
+var secretFlags = map[string]bool{"opsgenie-api-key": true, "gchat-webhook-url": true, "github-header": true, "slack-webhook": true}
+
+// dumpConfig writes the effective value of every flag as JSON, after environment defaults are applied,
+// with credentials redacted
+func dumpConfig(w io.Writer) error {
+	flags := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		v := f.Value.String()
+		if v != "" && secretFlags[f.Name] {
+			v = "REDACTED"
+		}
+		flags[f.Name] = v
+	})
+
+	env := map[string]string{}
+	for _, k := range secretEnv {
+		env[k] = "unset"
+		if os.Getenv(k) != "" {
+			env[k] = "REDACTED"
+		}
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(map[string]any{"flags": flags, "env": env})
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run runs the alerter, returning its exit status. Errors that end the run panic, exiting with status 2
+// once deferred cleanup has run.
+func run() int {
+	flag.Parse()
+
+	if *versionFlag {
+		fmt.Printf("github-audit-alerter %s (commit %s, built %s)\n", version, commit, date)
+		return 0
+	}
+
+	if *opsgenieKeyFlag == "" {
+		*opsgenieKeyFlag = os.Getenv("OPSGENIE_API_KEY")
+	}
+	if *gchatWebhookFlag == "" {
+		*gchatWebhookFlag = os.Getenv("GH_AUDIT_GCHAT_WEBHOOK")
+	}
+	if len(*slackWebhookFlag) == 0 && os.Getenv("GH_AUDIT_SLACK_WEBHOOK") != "" {
+		*slackWebhookFlag = []string{os.Getenv("GH_AUDIT_SLACK_WEBHOOK")}
+	}
+
+	if *dumpConfigFlag {
+		if err := dumpConfig(os.Stdout); err != nil {
+			log.Fatalf("dump config: %v", err)
+		}
+		return 0
+	}
+
+	if *printSchemaFlag {
+		if err := printSchema(os.Stdout); err != nil {
+			log.Fatalf("print schema: %v", err)
+		}
+		return 0
+	}
+
+	if os.Getenv("GITHUB_TOKEN") == "" && *startupRetriesFlag == 0 {
+		log.Fatalf("GITHUB_TOKEN must be set")
+	}
+
+	if *orgFlag == "" {
+		log.Fatalf("--org must be passed")
+	}
+
+	if *outputFlag != "" && !slices.Contains(outputFormats, *outputFlag) {
+		log.Fatalf("--output must be one of %s, got %q", strings.Join(outputFormats, ", "), *outputFlag)
+	}
+	if *phraseOrderFlag != "asc" && *phraseOrderFlag != "desc" {
+		log.Fatalf("--phrase-order must be asc or desc, not %q", *phraseOrderFlag)
+	}
+
+	if !smtpTLSModes[*smtpTLSFlag] {
+		log.Fatalf("--smtp-tls must be starttls, tls, or none, not %q", *smtpTLSFlag)
+	}
+	if *dailyReportToFlag != "" && (*smtpAddrFlag == "" || *smtpFromFlag == "") {
+		log.Fatalf("--daily-report-to requires --smtp-addr and --smtp-from")
+	}
+
+	if !visibilityFlipModes[*visibilityFlipsFlag] {
+		log.Fatalf("--visibility-flips must be alert, collapse, or suppress, not %q", *visibilityFlipsFlag)
+	}
+
+	if err := validateWindows(*intervalFlag, *cloneIntervalFlag); err != nil {
+		log.Fatalf("invalid windows: %v", err)
+	}
+
+	criticalRepos := strings.Split(*criticalReposFlag, ",")
+	if *criticalReposFileFlag != "" {
+		repos, err := readRepoFile(*criticalReposFileFlag)
+		if err != nil {
+			log.Fatalf("critical repos file: %v", err)
+		}
+		criticalRepos = append(criticalRepos, repos...)
+	}
+
+	criticalIDs, criticalRepos, err := splitRepoIDs(criticalRepos)
+	if err != nil {
+		log.Fatalf("critical repos: %v", err)
+	}
+
+	critical, err := normalizeRepos(*orgFlag, criticalRepos)
+	if err != nil {
+		log.Fatalf("critical repos: %v", err)
+	}
+
+	fields, err := parseFields(*fieldsFlag)
+	if err != nil {
+		log.Fatalf("fields: %v", err)
+	}
+
+	ignoreCIDRs, err := parseCIDRs(*ignoreCIDRsFlag)
+	if err != nil {
+		log.Fatalf("ignore cidrs: %v", err)
+	}
+	if *ignoreCIDRsURLFlag != "" {
+		published, err := trustedCIDRs(context.Background(), *ignoreCIDRsURLFlag, stateFile("trusted-cidrs"), *ignoreCIDRsRefreshFlag)
+		if err != nil {
+			log.Printf("WARNING: no trusted networks from %s: %v", *ignoreCIDRsURLFlag, err)
+		}
+		ignoreCIDRs = append(ignoreCIDRs, published...)
+	}
+
+	allowedCountries, err := parseCountries(*allowedCountriesFlag)
+	if err != nil {
+		log.Fatalf("allowed countries: %v", err)
+	}
+	blockedCountries, err := parseCountries(*blockedCountriesFlag)
+	if err != nil {
+		log.Fatalf("blocked countries: %v", err)
+	}
+
+	foundLog.perSecond = *foundLogRateFlag
+
+	botPatterns, err := parseBotPatterns(*botRegexpFlag)
+	if err != nil {
+		log.Fatalf("bot regexp: %v", err)
+	}
+
+	repoSeverities, err := parseRepoSeverities(*orgFlag, *repoSeverityFlag)
+	if err != nil {
+		log.Fatalf("repo severity: %v", err)
+	}
+
+	includes, err := parseAuditIncludes(*auditIncludeFlag)
+	if err != nil {
+		log.Fatalf("audit include: %v", err)
+	}
+
+	if *webhookSuccessFlag != "" {
+		check, err := parseResponseCheck(*webhookSuccessFlag)
+		if err != nil {
+			log.Fatalf("webhook success: %v", err)
+		}
+		webhookClient.Transport = checkingTransport{rt: http.DefaultTransport, check: check}
+	}
+
+	extractions, err := parseExtractions(*extractFlag)
+	if err != nil {
+		log.Fatalf("extract: %v", err)
+	}
+
+	runbooks, err := parseRunbooks(*runbookFlag, *defaultRunbookFlag)
+	if err != nil {
+		log.Fatalf("runbook: %v", err)
+	}
+	categories, err := parseCategories(*categoryFlag)
+	if err != nil {
+		log.Fatalf("category: %v", err)
+	}
+
+	timeLayout = *timeFormatFlag
+	if preset, ok := timePresets[strings.ToLower(timeLayout)]; ok {
+		timeLayout = preset
+	}
+	if *timeZoneFlag != "" {
+		timeLocation, err = time.LoadLocation(*timeZoneFlag)
+		if err != nil {
+			log.Fatalf("time zone: %v", err)
+		}
+	}
+
+	headers, err := parseHeaders(*githubHeaderFlag)
+	if err != nil {
+		log.Fatalf("github header: %v", err)
+	}
+
+	if *statsdAddrFlag != "" {
+		tags, err := parseStatsdTags(*statsdTagsFlag)
+		if err != nil {
+			log.Fatalf("statsd tags: %v", err)
+		}
+		metrics, err = newStatsd(*statsdAddrFlag, *statsdPrefixFlag, append([]string{"org:" + *orgFlag}, tags...))
+		if err != nil {
+			log.Fatalf("statsd: %v", err)
+		}
+		defer metrics.Close()
+	}
+
+	ctx := context.Background()
+	if metrics != nil {
+		// The oauth2 client sends GitHub API requests through this client, so that they are timed
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: statsdTransport{base: http.DefaultTransport}})
+	}
+	var c *github.Client
+	if *startupRetriesFlag == 0 {
+		c = newGitHubClient(ctx, os.Getenv("GITHUB_TOKEN"), headers)
+	} else {
+		c, err = startupClient(ctx, headers, *startupRetriesFlag, *startupRetryDelayFlag)
+		if err != nil {
+			log.Fatalf("startup: %v", err)
+		}
+	}
+
+	now := time.Now()
+	s := Settings{
+		Org:                      *orgFlag,
+		Since:                    now.Add(-1 * *intervalFlag),
+		BotNames:                 strings.Split(*botNameFlag, ","),
+		BotPatterns:              botPatterns,
+		GlobalIgnoreActions:      universalIgnore,
+		NonCriticalIgnoreActions: nonCriticalIgnore,
+		MaxClonedRepos:           *maxReposClonedFlag,
+		MaxClonesSince:           now.Add(-1 * *cloneIntervalFlag),
+		EventsInclude:            includes["events"],
+		ClonesInclude:            includes["clones"],
+		CloneBurstWindow:         *cloneBurstWindowFlag,
+		MinCloneDensity:          *cloneDensityFlag,
+		MaxCriticalClonedRepos:   *maxCriticalReposClonedFlag,
+		MinDistinctClonedRepos:   *minDistinctReposClonedFlag,
+		NewRepoGrace:             *newRepoGraceFlag,
+		MaxAge:                   *maxAgeFlag,
+		IncludePublicClones:      *includePublicClonesFlag,
+		IncludeBots:              *includeBotsFlag,
+		VisibilityFlips:          *visibilityFlipsFlag,
+		TokenCloneWindow:         *tokenCloneWindowFlag,
+		VisibilityCloneWindow:    *visibilityCloneWindowFlag,
+		MaxOutsideCollaborators:  *maxCollaboratorsFlag,
+		CollaboratorBurstWindow:  *collaboratorBurstWindowFlag,
+		MaxGrantsPerUser:         *maxGrantsFlag,
+		GrantBurstWindow:         *grantBurstWindowFlag,
+		MaxReposCreated:          *maxReposCreatedFlag,
+		RepoCreationBurstWindow:  *repoCreationBurstWindowFlag,
+		CriticalRepos:            critical,
+		IgnoreCIDRs:              ignoreCIDRs,
+		AllowedCountries:         allowedCountries,
+		BlockedCountries:         blockedCountries,
+		Emoji:                    *emojiFlag,
+		Fields:                   fields,
+		RepoSeverities:           repoSeverities,
+		Runbooks:                 runbooks,
+		Categories:               categories,
+	}
+
+	if *alertSecurityDowngradeFlag {
+		s.Overrides = append(s.Overrides, newOverride("security-downgrade", securityDowngradeActions, nil))
+	}
+
+	if *alertWorkflowPermsFlag {
+		s.Overrides = append(s.Overrides, newOverride("workflow-perms", workflowPermsActions, workflowPermsEscalation))
+	}
+
+	if *alertAuditAccessFlag {
+		s.Overrides = append(s.Overrides, newOverride("audit-access", auditAccessActions, nil))
+	}
+
+	if *alertAppInstallsFlag {
+		s.Overrides = append(s.Overrides, newOverride("app", appInstallActions, nil))
+	}
+
+	if *alertForcePushesFlag {
+		s.Overrides = append(s.Overrides, newOverride("force-push", forcePushActions, nil))
+	}
+
+	if *alertFeatureDisabledFlag {
+		s.Overrides = append(s.Overrides, newOverride("feature-disabled", featureToggleActions, featureDisabled))
+	}
+
+	if *alertTeamAdminFlag {
+		s.Overrides = append(s.Overrides, newOverride("team-admin", teamRepoPermissionActions, teamAdminGrant))
+	}
+
+	if *alertOrgSecretsFlag {
+		s.Overrides = append(s.Overrides, newOverride("org-secret", orgSecretActions, nil))
+	}
+
+	if *alertDismissalsFlag {
+		actions := dismissalActions
+		if *alertAutoDismissalsFlag {
+			actions = append(actions, autoDismissalActions...)
+		}
+		s.Overrides = append(s.Overrides, newOverride("dismissal", actions, nil))
+	}
+
+	if *repoFilterFileFlag != "" {
+		s.RepoFilter, err = readRepoFilter(*repoFilterFileFlag, s.Org)
+		if err != nil {
+			log.Fatalf("repo filter: %v", err)
+		}
+	}
+
+	if *learnNewActionsFlag {
+		s.LearnedActions, err = loadLearnedActions(stateFile("learned-actions.json"), *learnGraceFlag)
+		if err != nil {
+			log.Fatalf("learned actions: %v", err)
+		}
+	}
+
+	if *dedupeCloneBurstsFlag {
+		s.CloneBursts, err = loadCloneBursts(stateFile("clone-bursts.json"))
+		if err != nil {
+			log.Fatalf("clone bursts: %v", err)
+		}
+	}
+
+	if *resolveActorIDsFlag {
+		actorLogins, err = loadActorResolver(c, stateFile("actor-logins.json"))
+		if err != nil {
+			log.Fatalf("actor logins: %v", err)
+		}
+	}
+
+	if *checkPermissionsFlag {
+		if !checkPermissions(ctx, c, s) {
+			return 1
+		}
+		return 0
+	}
+
+	if len(criticalIDs) > 0 {
+		names, failed, err := repoNamesByID(ctx, c, criticalIDs)
+		if err != nil {
+			log.Printf("WARNING: %d critical repo IDs could not be looked up, and are not treated as critical: %v", len(failed), err)
+		}
+		for _, r := range names {
+			s.CriticalRepos.names[r] = true
+		}
+		log.Printf("critical repo IDs are currently named %v", names)
+	}
+
+	if *checkCriticalFlag && len(s.CriticalRepos.names) > 0 {
+		missing, err := missingRepos(ctx, c, s.Org, s.CriticalRepos.names)
+		if err != nil {
+			log.Printf("unable to verify critical repos: %v", err)
+		}
+		for _, r := range missing {
+			log.Printf("WARNING: critical repo %q does not exist in %s", r, s.Org)
+		}
+	}
+
+	if *criticalTopicFlag != "" {
+		topics := strings.Split(strings.ToLower(*criticalTopicFlag), ",")
+		repos, err := reposWithTopics(ctx, c, s.Org, topics)
+		if err != nil {
+			log.Printf("unable to list repos with critical topics, using only the explicit critical repos: %v", err)
+		}
+		for _, r := range repos {
+			s.CriticalRepos.names[r] = true
+		}
+		log.Printf("%d repos have a critical topic: %v", len(repos), repos)
+	}
+
+	var threader *slackThreader
+	if *slackThreadWindowFlag > 0 {
+		token := os.Getenv("GH_AUDIT_SLACK_TOKEN")
+		if token == "" || *slackChannelFlag == "" {
+			log.Fatalf("--slack-thread-window requires GH_AUDIT_SLACK_TOKEN and --slack-channel")
+		}
+		threader, err = loadSlackThreader(token, *slackChannelFlag, stateFile("slack-threads"), *slackThreadWindowFlag)
+		if err != nil {
+			log.Fatalf("slack threads: %v", err)
+		}
+	}
+	notifiers := slackNotifiers(*slackWebhookFlag, threader, *slackThreadWebhooksFlag)
+	if *issueRepoFlag != "" {
+		in, err := newIssueNotifier(c, *issueRepoFlag)
+		if err != nil {
+			log.Fatalf("issue repo: %v", err)
+		}
+		notifiers = append(notifiers, in)
+	}
+
+	if *opsgenieKeyFlag != "" {
+		notifiers = append(notifiers, &opsgenieNotifier{
+			apiURL: *opsgenieURLFlag,
+			apiKey: *opsgenieKeyFlag,
+		})
+	}
+
+	if *gchatWebhookFlag != "" {
+		notifiers = append(notifiers, gchatNotifier{url: *gchatWebhookFlag})
+	}
+
+	for _, newNotifier := range optionalNotifiers {
+		n, err := newNotifier()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if n != nil {
+			notifiers = append(notifiers, n)
+		}
+	}
+
+	if *circuitFailuresFlag > 0 || *floodAlertsFlag > 0 {
+		for i, n := range notifiers {
+			notifiers[i] = &breaker{
+				n:           n,
+				maxFailures: *circuitFailuresFlag,
+				cooldown:    *circuitCooldownFlag,
+				maxAlerts:   *floodAlertsFlag,
+				window:      *floodWindowFlag,
+			}
+		}
+	}
+
+	// Output is written locally, so it is added after the breakers: it needs no circuit breaking,
+	// and SARIF needs every alert rather than a flood summary. Paused and first runs send nothing, so
+	// they leave the output file alone rather than truncating the last SARIF log.
+	paused := *pauseFileFlag != "" && checkPaused(*pauseFileFlag, stateFile("paused"))
+	if *outputFlag != "" && !paused && !*firstRunFlag {
+		w := io.Writer(os.Stdout)
+		if *outputFileFlag != "" {
+			mode := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+			if *outputFlag == "sarif" {
+				mode = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+			f, err := os.OpenFile(*outputFileFlag, mode, 0o600)
+			if err != nil {
+				log.Fatalf("output file: %v", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		if *outputFlag == "sarif" {
+			notifiers = append(notifiers, &sarifNotifier{w: w, vendor: *outputVendorFlag, product: *outputProductFlag, version: *outputVersionFlag})
+		} else {
+			notifiers = append(notifiers, siemNotifier{
+				w:       w,
+				format:  *outputFlag,
+				vendor:  *outputVendorFlag,
+				product: *outputProductFlag,
+				version: *outputVersionFlag,
+			})
+		}
+	}
+
+	if len(*redactFlag) > 0 {
+		res, err := compileRedactions(*redactFlag)
+		if err != nil {
+			log.Fatalf("redact: %v", err)
+		}
+		for i, n := range notifiers {
+			notifiers[i] = redactor{n: n, res: res}
+		}
+	}
+
+	if paused {
+		notifiers = []notifier{pausedNotifier{}}
+	}
+	// A first run only records what it finds, so that later runs alert on new activity rather than history
+	if *firstRunFlag {
+		log.Printf("first run: seeding %s, so alerts will be logged but not sent", *stateDirFlag)
+		notifiers = []notifier{firstRunNotifier{}}
+	}
+
+	// flushPending sends alerts held by --coalesce-actors, so that they are not lost if a later detector fails
+	flushPending := func() {}
+	fail := func(format string, args ...any) {
+		flushPending()
+		err := fmt.Errorf(format, args...)
+		if *notifyOnErrorFlag {
+			notifyError(ctx, notifiers, s.Org, err)
+		}
+		log.Panic(err)
+	}
+
+	stats := &runStats{}
+	if *deadLetterFileFlag != "" {
+		stats.deadLetters = &deadLetters{path: *deadLetterFileFlag, maxBytes: *deadLetterMaxBytesFlag}
+		// Retrying while paused would drop the dead letters without sending them
+		if *retryDeadLetterFlag && !paused && !*firstRunFlag {
+			if err := stats.deadLetters.retry(ctx, notifiers, stats); err != nil {
+				log.Printf("retry dead letters: %v", err)
+			}
+		}
+	}
+
+	// Ad-hoc searches are not detections, so they are not reported on, and neither are the alerts a first run only records
+	var report *dailyReport
+	if *dailyReportToFlag != "" && *phraseFlag == "" && !*firstRunFlag {
+		report, err = loadDailyReport(stateFile("daily-report.json"), now)
+		if err != nil {
+			log.Fatalf("daily report: %v", err)
+		}
+		loc := timeLocation
+		if loc == nil {
+			loc = time.UTC
+		}
+		// The report is kept until it is sent, so that a day is never lost to a failed or paused run
+		if report.due(now, loc) && !paused {
+			if err := emailReport(report, s.Org, now); err != nil {
+				stats.failures++
+				stats.lastErr = err
+				log.Printf("daily report: %v", err)
+			} else {
+				report.reset(now)
+			}
+		}
+	}
+
+	var th *throttle
+	if *throttleMaxFlag > 0 && !*firstRunFlag {
+		var summary string
+		th, summary, err = loadThrottle(stateFile("throttle"), *throttleMaxFlag, *throttleWindowFlag)
+		if err != nil {
+			log.Fatalf("throttle: %v", err)
+		}
+		if summary != "" {
+			stats.notify(ctx, notifiers, newAlert(nil, "", summary, s.CriticalRepos))
+		}
+	}
+
+	var cd *cooldown
+	if (len(*cooldownFlag) > 0 || *defaultCooldownFlag > 0) && *phraseFlag == "" {
+		windows, err := parseCooldowns(*cooldownFlag)
+		if err != nil {
+			log.Fatalf("cooldown: %v", err)
+		}
+		cd, err = loadCooldown(stateFile("cooldown.json"), windows, *defaultCooldownFlag)
+		if err != nil {
+			log.Fatalf("cooldown: %v", err)
+		}
+	}
+
+	enrichers, err := newEnrichers(c, *enrichFlag)
+	if err != nil {
+		log.Fatalf("enrich: %v", err)
+	}
+	if len(extractions) > 0 {
+		enrichers = append(enrichers, extractEnricher{rules: extractions})
+	}
+
+	// Ad-hoc searches are not detections, so they do not count towards repeat offenses
+	var rep *offenders
+	if *repeatOffenderRunsFlag != "" && *phraseFlag == "" {
+		steps, err := parseSteps(*repeatOffenderRunsFlag)
+		if err != nil {
+			log.Fatalf("repeat offender runs: %v", err)
+		}
+		rep, err = loadOffenders(stateFile("offenders.json"), steps)
+		if err != nil {
+			log.Fatalf("repeat offenders: %v", err)
+		}
+	}
+
+	hook := hookPayload{Org: s.Org, Since: s.Since, Counts: alertCounts{}, Alerts: []*alert{}}
+	counts := alertCounts{}
+	// overflow counts alerts past --max-alerts-per-run, posted together at the end
+	overflow := alertCounts{}
+	found, posted := 0, 0
+	// highest is the most severe alert found, for --severity-exit-codes
+	highest := ""
+	send := func(al *alert) {
+		found++
+		al.Category = categoryFor(al.Entry, s.Categories)
+		if *includeTraceFlag && al.Entry != nil {
+			al.Trace = newTrace(al, s, *phraseFlag != "")
+		}
+		prev := al.Severity
+		if locationPrefix(al.Entry, s) == "blocked-location" {
+			al.raise(severityCritical)
+		}
+		al.Trace.raised(prev, al.Severity, "blocked location")
+		prev = al.Severity
+		applyRepoSeverity(al, s.RepoSeverities)
+		al.Trace.raised(prev, al.Severity, "--repo-severity")
+		prev = al.Severity
+		rep.escalate(al)
+		al.Trace.raised(prev, al.Severity, "repeat offender")
+		if highest == "" || severityRank(al.Severity) > severityRank(highest) {
+			highest = al.Severity
+		}
+		hook.Alerts = append(hook.Alerts, al)
+		hook.Counts[alertCategory(al)]++
+		report.add(al)
+		if *summaryOnlyFlag && !alwaysAlert(al.Entry) {
+			counts[alertCategory(al)]++
+			return
+		}
+		if !cd.allow(al, now) {
+			return
+		}
+		if th.allow(al) {
+			if *maxAlertsPerRunFlag > 0 && al.Severity != severityCritical {
+				if posted >= *maxAlertsPerRunFlag {
+					log.Printf("over --max-alerts-per-run, summarizing: %s", al)
+					overflow[alertCategory(al)]++
+					return
+				}
+				posted++
+			}
+			if *correlationBucketFlag > 0 && al.Entry != nil {
+				al.addDetail("correlation", correlationID(al.Entry, *correlationBucketFlag))
+			}
+			enrich(ctx, enrichers, al)
+			stats.notify(ctx, notifiers, al)
+		}
+	}
+
+	// With --coalesce-actors, web event and clone alerts are held until both detectors have run
+	pending := []*alert{}
+	emit := send
+	if *coalesceActorsFlag {
+		emit = func(al *alert) { pending = append(pending, al) }
+		flushPending = func() {
+			for _, al := range coalesceActors(pending) {
+				send(al)
+			}
+			pending = nil
+		}
+	}
+
+	if *phraseFlag != "" {
+		pes, err := phraseEvents(ctx, c, s, *phraseFlag)
+		if err != nil {
+			fail("phrase events: %w", err)
+		}
+		for _, e := range pes {
+			al := newAlert(e, "", auditMsg(e, s), s.CriticalRepos)
+			fmt.Println(al)
+			if *phraseNotifyFlag {
+				send(al)
+			}
+		}
+	} else {
+		// The web log is fetched once, back as far as any detector needs it
+		web, err := auditLog(ctx, c, s.EventsInclude, webLogSince(s))
+		if err != nil {
+			fail("web events: %w", err)
+		}
+		wes := webEvents(web, s)
+		if s.LearnedActions != nil {
+			if err := s.LearnedActions.save(); err != nil {
+				log.Printf("save learned actions: %v", err)
+			}
+		}
+		for _, e := range wes {
+			emit(newAlert(e, "", auditMsg(e, s), s.CriticalRepos))
+		}
+
+		if s.MaxOutsideCollaborators > 0 {
+			oes := burstEvents(web, s, collaboratorBurst(s))
+			for _, e := range oes {
+				emit(newAlert(e, fmt.Sprintf("outside collaborator burst[>=%d]", s.MaxOutsideCollaborators), auditMsg(e, s), s.CriticalRepos))
+			}
+		}
+
+		if s.MaxGrantsPerUser > 0 {
+			ges := burstEvents(web, s, grantBurst(s))
+			for _, e := range ges {
+				emit(newAlert(e, fmt.Sprintf("access grant burst[>=%d]", s.MaxGrantsPerUser), auditMsg(e, s), s.CriticalRepos))
+			}
+		}
+
+		if s.MaxReposCreated > 0 {
+			res := burstEvents(web, s, repoCreationBurst(s))
+			for _, e := range res {
+				emit(newAlert(e, fmt.Sprintf("repo creation burst[>=%d]", s.MaxReposCreated), auditMsg(e, s), s.CriticalRepos))
+			}
+		}
+
+		ces, err := cloneEvents(ctx, c, s)
+		if err != nil {
+			fail("clone events: %w", err)
+		}
+		tokens := map[string][]time.Time{}
+		if s.TokenCloneWindow > 0 && len(ces) > 0 {
+			tokens = tokenCreations(web, s)
+		}
+		visibility := map[string][]time.Time{}
+		if s.VisibilityCloneWindow > 0 && len(ces) > 0 {
+			visibility = visibilityChanges(web, s)
+		}
+
+		for _, e := range ces {
+			kind := fmt.Sprintf("excessive clone[>=%d]", s.MaxClonedRepos)
+			if s.MinCloneDensity > 0 {
+				kind = fmt.Sprintf("excessive clone[>=%g/min]", s.MinCloneDensity)
+			}
+			if t, ok := tokenPrecursor(e, tokens, s.TokenCloneWindow); ok {
+				kind = fmt.Sprintf("%s after token created at %s", kind, t.Format(time.RFC3339))
+			}
+			t, changed := visibilityPrecursor(e, visibility, s.VisibilityCloneWindow)
+			if changed {
+				kind = fmt.Sprintf("%s after visibility changed at %s", kind, t.Format(time.RFC3339))
+			}
+			al := newAlert(e, kind, auditMsg(e, s), s.CriticalRepos)
+			if changed {
+				al.Severity = severityCritical
+			}
+			emit(al)
+		}
+
+		flushPending()
+	}
+
+	if *notifyEmptyFlag && *phraseFlag == "" && found == 0 {
+		notifyEmpty(ctx, notifiers, s.Org, s.Since)
+	}
+
+	if len(counts) > 0 {
+		stats.notify(ctx, notifiers, newAlert(nil, "", fmt.Sprintf("%d alerts for %s since %s:\n%s", counts.total(), s.Org, s.Since.Format(time.RFC3339), counts), s.CriticalRepos))
+	}
+
+	if len(overflow) > 0 {
+		stats.notify(ctx, notifiers, newAlert(nil, "", fmt.Sprintf("+%d more events for %s since %s:\n%s", overflow.total(), s.Org, s.Since.Format(time.RFC3339), overflow), s.CriticalRepos))
+	}
+
+	for _, n := range notifiers {
+		if f, ok := n.(flusher); ok {
+			if err := f.Flush(ctx); err != nil {
+				stats.failures++
+				stats.lastErr = err
+				log.Printf("flush failed: %v", err)
+			}
+		}
+	}
+
+	if th != nil {
+		if err := th.save(); err != nil {
+			log.Printf("save throttle: %v", err)
+		}
+	}
+
+	if cd != nil {
+		if err := cd.save(now); err != nil {
+			log.Printf("save cooldown: %v", err)
+		}
+	}
+
+	if rep != nil {
+		if err := rep.save(); err != nil {
+			log.Printf("save repeat offenders: %v", err)
+		}
+	}
+
+	if s.CloneBursts != nil {
+		if err := s.CloneBursts.save(s.MaxClonesSince); err != nil {
+			log.Printf("save clone bursts: %v", err)
+		}
+	}
+
+	if report != nil {
+		if err := report.save(); err != nil {
+			log.Printf("save daily report: %v", err)
+		}
+	}
+
+	if threader != nil {
+		if err := threader.save(); err != nil {
+			log.Printf("save slack threads: %v", err)
+		}
+	}
+
+	if actorLogins != nil {
+		if err := actorLogins.save(); err != nil {
+			log.Printf("save actor logins: %v", err)
+		}
+	}
+
+	foundLog.Flush()
+	stats.scanned = entriesScanned
+	log.Printf("summary: %s", stats)
+	metrics.timing("run.duration", time.Since(now))
+
+	if *firstRunFlag {
+		log.Printf("first run: recorded %d alerts in %s without sending them", found, *stateDirFlag)
+		return 0
+	}
+
+	if *postRunHookFlag != "" {
+		hook.Scanned, hook.Attempted, hook.Delivered, hook.Held, hook.Failures = stats.scanned, stats.attempted, stats.delivered, stats.held, stats.failures
+		if err := runHook(ctx, *postRunHookFlag, *postRunHookTimeoutFlag, hook); err != nil {
+			if *postRunHookFailFlag {
+				fail("%w", err)
+			}
+			log.Printf("%v", err)
+		}
+	}
+	if stats.failures > 0 {
+		fail("%d delivery failures, last: %w", stats.failures, stats.lastErr)
+	}
+	if *severityExitCodesFlag && highest != "" {
+		log.Printf("exiting with status %d for %s severity alerts", severityExitCodes[highest], highest)
+		return severityExitCodes[highest]
+	}
+	return 0
+}
+
+// runStats summarizes the outcome of a run
+type runStats struct {
+	scanned   int
+	attempted int
+	delivered int
+	// held counts alerts that some notifier held back for a flood summary, rather than delivering them
+	held     int
+	failures int
+	// lastErr is the most recent delivery failure
+	lastErr error
+	// deadLetters, if set, records alerts that no notifier delivered
+	deadLetters *deadLetters
+}
+
+func (r *runStats) String() string {
+	s := fmt.Sprintf("%d events scanned, %d alerts attempted, %d delivered, %d delivery failures", r.scanned, r.attempted, r.delivered, r.failures)
+	if r.held > 0 {
+		s += fmt.Sprintf(", %d held for flood summaries", r.held)
+	}
+	return s
+}
+
+// notify sends an alert to each notifier, recording the outcome
+func (r *runStats) notify(ctx context.Context, ns []notifier, al *alert) {
+	errs := notifyAll(ctx, ns, al)
+	r.attempted++
+	failed := []error{}
+	held := false
+	for _, err := range errs {
+		if errors.Is(err, errHeld) {
+			held = true
+			continue
+		}
+		failed = append(failed, err)
+	}
+	r.failures += len(failed)
+	metrics.count("alerts", 1, "detector:"+metricDetector(al))
+	if len(failed) > 0 {
+		metrics.count("notify_failures", len(failed), "detector:"+metricDetector(al))
+	}
+	if held {
+		r.held++
+	}
+	if len(errs) == 0 {
+		r.delivered++
+	}
+	if len(failed) == 0 {
+		return
+	}
+	r.lastErr = failed[len(failed)-1]
+
+	// Local output always succeeds, so an alert is undelivered when every remote sink failed
+	remote := 0
+	for _, n := range ns {
+		if !isLocal(n) {
+			remote++
+		}
+	}
+	if r.deadLetters != nil && len(failed) >= remote {
+		if err := r.deadLetters.add(al); err != nil {
+			log.Printf("dead letter: %v", err)
+		}
+	}
+}
+
+// notifyError tells the notifiers that the alerter itself failed, at most once per --error-notify-interval
+func notifyError(ctx context.Context, ns []notifier, org string, err error) {
+	al := newAlert(nil, "", fmt.Sprintf("alerter error for %s: %v (github-audit-alerter %s)", org, err, version), repoSet{})
+	al.Fingerprint = fingerprint(nil)
+	notifyAtMostEvery(ctx, ns, stateFile("error-notified"), *errorNotifyIntervalFlag, al)
+}
+
+// notifyEmpty tells the notifiers that a run found nothing to alert on, at most once per --notify-empty-interval
+func notifyEmpty(ctx context.Context, ns []notifier, org string, since time.Time) {
+	notifyAtMostEvery(ctx, ns, stateFile("empty-notified"), *notifyEmptyIntervalFlag,
+		newAlert(nil, "", fmt.Sprintf("no alerts for %s since %s (github-audit-alerter %s)", org, since.Format(time.RFC3339), version), repoSet{}))
+}
+
+// notifyAtMostEvery sends an alert unless it was last sent less than interval ago, as recorded in path
+func notifyAtMostEvery(ctx context.Context, ns []notifier, path string, interval time.Duration, al *alert) {
+	if b, rerr := os.ReadFile(path); rerr == nil {
+		last, perr := time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+		if perr == nil && time.Since(last) < interval {
+			log.Printf("suppressing notification, last sent at %s: %s", last, al)
+			return
+		}
+	}
+
+	notifyAll(ctx, ns, al)
+	if werr := writeFileAtomic(path, []byte(time.Now().Format(time.RFC3339))); werr != nil {
+		log.Printf("unable to record notification in %s: %v", path, werr)
+	}
+}
+
+// auditLocation returns the repository or org an audit entry applies to
+func auditLocation(a *github.AuditEntry) string {
+	repo := a.GetRepo()
+	if repo == "" {
+		repo = a.GetRepository()
+	}
+
+	if repo == "" {
+		return a.GetOrg()
+	}
+	if strings.Contains(repo, "/") {
+		return repo
+	}
+	return fmt.Sprintf("%s/%s", a.GetOrg(), repo)
+}
+
+// fingerprint returns a stable identifier for an audit entry. Alerter errors have no entry, and share one.
+func fingerprint(a *github.AuditEntry) string {
+	if a.GetDocumentID() != "" {
+		return a.GetDocumentID()
+	}
+	sum := sha256.Sum256([]byte(auditString(a)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// actionEmojiRes holds the compiled actionEmoji patterns, in the same order
+var actionEmojiRes = func() []*regexp.Regexp {
+	res := []*regexp.Regexp{}
+	for _, m := range actionEmoji {
+		res = append(res, actionRegexp([]string{m.pattern}))
+	}
+	return res
+}()
+
+// emojiFor returns the emoji for an action, as configured by actionEmoji
+func emojiFor(action string) string {
+	for i, re := range actionEmojiRes {
+		if re.MatchString(action) {
+			return actionEmoji[i].emoji
+		}
+	}
+	return defaultEmoji
+}
+
+// msgField renders one field of an alert message; an empty result omits the field
+type msgField struct {
+	// sep is written before the field, unless it is the first
+	sep    string
+	render func(a *github.AuditEntry) string
+}
+
+var (
+	// msgFields are the fields that may be passed to --fields
+	msgFields = map[string]msgField{
+		"actor":    {" ", func(a *github.AuditEntry) string { return actorName(a) + ":" }},
+		"action":   {" ", func(a *github.AuditEntry) string { return fmt.Sprintf("*%s*", a.GetAction()) }},
+		"location": {" ", func(a *github.AuditEntry) string { return fmt.Sprintf("on *%s*", auditLocation(a)) }},
+		"visibility": {" ", func(a *github.AuditEntry) string {
+			if a.GetPreviousVisibility() == "" {
+				return ""
+			}
+			return fmt.Sprintf("visibility: %s->%s", a.GetPreviousVisibility(), a.GetVisibility())
+		}},
+		"user":        {" ", func(a *github.AuditEntry) string { return quotedField("user", a.GetUser()) }},
+		"name":        {" ", func(a *github.AuditEntry) string { return quotedField("name", a.GetName()) }},
+		"explanation": {" ", func(a *github.AuditEntry) string { return quotedField("explanation", a.GetExplanation()) }},
+		"timestamp": {": ", func(a *github.AuditEntry) string {
+			ts := a.GetCreatedAt()
+			if ts.IsZero() {
+				ts = a.GetTimestamp()
+			}
+			return formatTime(ts.Time)
+		}},
+		"link": {" ", func(a *github.AuditEntry) string { return fmt.Sprintf("[<%s|logs>]", auditLogURL(a)) }},
+		// The link searches by action and actor, which can match several entries; the document ID is exact
+		"document": {" ", func(a *github.AuditEntry) string { return quotedField("document_id", a.GetDocumentID()) }},
+		"profile": {" ", func(a *github.AuditEntry) string {
+			// Apps act as "name[bot]", which has no profile page
+			if a.GetActor() == "" || strings.HasSuffix(a.GetActor(), "[bot]") {
+				return ""
+			}
+			return fmt.Sprintf("<https://github.com/%s|@%s>", url.PathEscape(a.GetActor()), a.GetActor())
+		}},
+	}
+
+	// defaultFields are the fields of an alert message when --fields is unset, all but profile
+	defaultFields = []string{"actor", "action", "location", "visibility", "user", "name", "explanation", "timestamp", "link", "document"}
+)
+
+// auditLogURL links to the org's audit log, searching for the entry's action and actor, if known
+func auditLogURL(a *github.AuditEntry) string {
+	u := url.URL{
+		Scheme: "https",
+		Host:   "github.com",
+		Path:   fmt.Sprintf("/organizations/%s/settings/audit-log", a.GetOrg()),
+	}
+	q := u.Query()
+	terms := "action:" + a.GetAction()
+	if a.GetActor() != "" {
+		terms += " actor:" + a.GetActor()
+	}
+	q.Set("q", terms)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// timePresets are the named layouts accepted by --time-format
+var timePresets = map[string]string{
+	"datetime": time.DateTime,
+	"kitchen":  time.Kitchen,
+	"rfc1123":  time.RFC1123,
+	"rfc3339":  time.RFC3339,
+	"stamp":    time.Stamp,
+}
+
+// timeLayout and timeLocation are how alert timestamps are rendered, from --time-format and --time-zone
+var (
+	timeLayout   string
+	timeLocation *time.Location
+)
+
+// formatTime renders a timestamp, as time.Time's String does unless --time-format or --time-zone are set
+func formatTime(t time.Time) string {
+	if timeLocation != nil {
+		t = t.In(timeLocation)
+	}
+	if timeLayout == "" {
+		return t.String()
+	}
+	return t.Format(timeLayout)
+}
+
+func quotedField(name string, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s: %q", name, value)
+}
+
+// parseFields parses the --fields flag, returning the default fields when it is empty
+func parseFields(flag string) ([]string, error) {
+	if strings.TrimSpace(flag) == "" {
+		return defaultFields, nil
+	}
+
+	fields := []string{}
+	for _, f := range strings.Split(flag, ",") {
+		f = strings.TrimSpace(f)
+		if _, ok := msgFields[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q, must be one of %v", f, defaultFields)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func auditMsg(a *github.AuditEntry, s Settings) string {
+	var sb strings.Builder
+
+	if s.Emoji {
+		sb.WriteString(emojiFor(a.GetAction()) + " ")
+	}
+
+	if o := matchOverride(a, s.Overrides); o != nil {
+		sb.WriteString(o.prefix + ": ")
+	}
+
+	if p := locationPrefix(a, s); p != "" {
+		sb.WriteString(p + ": ")
+	}
+
+	if s.IncludeBots && isBot(actorName(a), s.BotNames, s.BotPatterns) {
+		sb.WriteString("bot: ")
+	}
+
+	fields := s.Fields
+	if len(fields) == 0 {
+		fields = defaultFields
+	}
+
+	first := true
+	for _, f := range fields {
+		mf := msgFields[f]
+		text := mf.render(a)
+		if text == "" {
+			continue
+		}
+		if !first {
+			sb.WriteString(mf.sep)
+		}
+		sb.WriteString(text)
+		first = false
+	}
+
+	if u := runbookURL(a, s.Runbooks); u != "" {
+		sb.WriteString(fmt.Sprintf(" [<%s|runbook>]", u))
+	}
+
+	return sb.String()
+}
+
+// alertTitle returns a one-line summary of an alert, for sinks with a separate title
+func alertTitle(a *github.AuditEntry, text string) string {
+	if a == nil {
+		title, _, _ := strings.Cut(text, "\n")
+		return title
+	}
+	return fmt.Sprintf("%s by %s on %s", a.GetAction(), actorName(a), auditLocation(a))
+}
+
+// Alert severities, from most to least severe
+const (
+	severityCritical = "critical"
+	severityHigh     = "high"
+	severityMedium   = "medium"
+)
+
+// severityLevels orders alert severities from least to most severe
+var severityLevels = []string{severityMedium, severityHigh, severityCritical}
+
+// severityRank returns the index of sev in severityLevels, or 0 if it is unknown
+func severityRank(sev string) int {
+	for i, l := range severityLevels {
+		if l == sev {
+			return i
+		}
+	}
+	return 0
+}
+
+// repoSeverity sets the minimum severity of alerts about matching repos
+type repoSeverity struct {
+	repos    repoSet
+	severity string
+}
+
+// parseRepoSeverities parses "repo=severity" pairs, where repo may be a glob and is prefixed with org if bare
+func parseRepoSeverities(org string, pairs []string) ([]repoSeverity, error) {
+	rs := []repoSeverity{}
+	for _, p := range pairs {
+		repo, sev, ok := strings.Cut(p, "=")
+		if !ok || repo == "" || !slices.Contains(severityLevels, sev) {
+			return nil, fmt.Errorf("%q is not in repo=severity form, with severity one of %s", p, strings.Join(severityLevels, ", "))
+		}
+		repos, err := normalizeRepos(org, []string{repo})
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, repoSeverity{repos: repos, severity: sev})
+	}
+	return rs, nil
+}
+
+// repoSeverityFor returns the highest severity configured for an entry's repo, or "" if there is none
+func repoSeverityFor(a *github.AuditEntry, rs []repoSeverity) string {
+	sev := ""
+	loc := auditLocation(a)
+	for _, r := range rs {
+		if r.repos.has(loc) && (sev == "" || severityRank(r.severity) > severityRank(sev)) {
+			sev = r.severity
+		}
+	}
+	return sev
+}
+
+// applyRepoSeverity raises an alert to the highest severity configured for its repo, mentioning the Slack channel
+// if it is raised to critical. Severities are never lowered.
+func applyRepoSeverity(al *alert, rs []repoSeverity) {
+	if al.Entry == nil {
+		return
+	}
+	if sev := repoSeverityFor(al.Entry, rs); sev != "" {
+		al.raise(sev)
+	}
+}
+
+// severityExitCodes are the exit statuses for the highest alert severity in a run with --severity-exit-codes
+var severityExitCodes = map[string]int{
+	severityMedium:   10,
+	severityHigh:     20,
+	severityCritical: 30,
+}
+
+// alertSeverity ranks an alert: events on critical repos and always-alert actions are critical, while
+// clone bursts and alerter errors (which have no entry) are high.
+func alertSeverity(a *github.AuditEntry, critical repoSet) string {
+	switch {
+	case a == nil:
+		return severityHigh
+	case critical.has(auditLocation(a)), alwaysAlert(a):
+		return severityCritical
+	case a.GetAction() == "git.clone":
+		return severityHigh
+	default:
+		return severityMedium
+	}
+}
+
+// alert is what notifiers deliver, each formatting it for its destination
+type alert struct {
+	// Entry is the audit entry alerted on, or nil for alerts about the alerter itself
+	Entry *github.AuditEntry `json:"entry,omitempty"`
+	// Kind names the detector that raised the alert, such as "excessive clone[>=3]", if any
+	Kind string `json:"kind,omitempty"`
+	// Message describes the alert in plain text
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	// Category groups the alert's action for SIEM rules, such as "secrets", or is "unknown"
+	Category string `json:"category"`
+	// Details are added by enrichers, in order
+	Details []alertDetail `json:"details,omitempty"`
+	// Trace is set for alerts about entries with --include-trace
+	Trace *alertTrace `json:"trace,omitempty"`
+	// Fingerprint identifies the alert to sinks that deduplicate, see newAlert
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Mention is set for always-alert actions, and alerts raised to critical, to mention the Slack channel
+	Mention bool `json:"mention,omitempty"`
+}
+
+// alertDetail is a named piece of additional information about an alert
+type alertDetail struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (al *alert) addDetail(name string, value string) {
+	al.Details = append(al.Details, alertDetail{Name: name, Value: value})
+}
+
+// raise sets the alert's severity to sev if that is higher, mentioning the Slack channel if it is now critical
+func (al *alert) raise(sev string) {
+	if severityRank(sev) <= severityRank(al.Severity) {
+		return
+	}
+	al.Severity = sev
+	al.Mention = al.Mention || sev == severityCritical
+}
+
+// newAlert fingerprints the alert by its entry, or if it has none, such as a summary, by its message and the time it was raised
+func newAlert(a *github.AuditEntry, kind string, msg string, critical repoSet) *alert {
+	fp := fingerprint(a)
+	if a == nil {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s", msg, time.Now().Format(time.RFC3339Nano))))
+		fp = hex.EncodeToString(sum[:16])
+	}
+	return &alert{Entry: a, Kind: kind, Message: msg, Severity: alertSeverity(a, critical), Category: unknownCategory, Fingerprint: fp, Mention: alwaysAlert(a)}
+}
+
+// fingerprint returns the alert's fingerprint, or its entry's for alerts built without newAlert
+func (al *alert) fingerprint() string {
+	if al.Fingerprint != "" {
+		return al.Fingerprint
+	}
+	return fingerprint(al.Entry)
+}
+
+// String renders the alert as a single plain text message
+func (al *alert) String() string {
+	var sb strings.Builder
+	if al.Kind != "" {
+		sb.WriteString(al.Kind + ": ")
+	}
+	sb.WriteString(al.Message)
+	for _, d := range al.Details {
+		sb.WriteString(fmt.Sprintf(" %s: %s", d.Name, d.Value))
+	}
+	return sb.String()
+}
+
+// correlationID identifies alerts by the same actor within the same bucket-long span, counted from the Unix epoch
+func correlationID(a *github.AuditEntry, bucket time.Duration) string {
+	// time.Truncate counts from Go's zero time, not the epoch, so the span is found from the Unix time instead
+	ns := a.GetTimestamp().UnixNano()
+	start := time.Unix(0, ns-ns%int64(bucket)).UTC()
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s", actorName(a), start.Format(time.RFC3339))))
+	return hex.EncodeToString(sum[:6])
+}
+
+// alertCategory groups alerts for --summary-only: by detector, or else by the action's category, such as "repo"
+func alertCategory(al *alert) string {
+	if al.Kind != "" {
+		name, _, _ := strings.Cut(al.Kind, "[")
+		return name
+	}
+	category, _, _ := strings.Cut(al.Entry.GetAction(), ".")
+	return category
+}
+
+// alertCounts tallies alerts per category
+type alertCounts map[string]int
+
+func (c alertCounts) total() int {
+	n := 0
+	for _, v := range c {
+		n += v
+	}
+	return n
+}
+
+// String lists the counts, one category per line, most frequent first
+func (c alertCounts) String() string {
+	categories := []string{}
+	for k := range c {
+		categories = append(categories, k)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if c[categories[i]] != c[categories[j]] {
+			return c[categories[i]] > c[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+
+	lines := []string{}
+	for _, k := range categories {
+		lines = append(lines, fmt.Sprintf("%s: %d", k, c[k]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// slackNotifiers returns a notifier for each webhook, so that one failing does not stop the others, or one
+// logging alerts if there are none. A threader replaces them, so that alerts are not posted twice to the same
+// channel, unless withWebhooks is set.
+func slackNotifiers(webhooks []string, threader *slackThreader, withWebhooks bool) []notifier {
+	ns := []notifier{}
+	if threader != nil {
+		ns = append(ns, threader)
+		if !withWebhooks {
+			return ns
+		}
+	}
+	for _, u := range webhooks {
+		ns = append(ns, slackNotifier{url: u})
+	}
+	if len(ns) == 0 {
+		ns = append(ns, slackNotifier{})
+	}
+	return ns
+}
+
+// notifier delivers alerts
+type notifier interface {
+	Notify(ctx context.Context, al *alert) error
+}
+
+// localNotifier is implemented by notifiers that write alerts locally, such as --output, rather than
+// delivering them to a remote sink
+type localNotifier interface {
+	local()
+}
+
+// isLocal reports whether n writes alerts locally, looking through --redact
+func isLocal(n notifier) bool {
+	if r, ok := n.(redactor); ok {
+		return isLocal(r.n)
+	}
+	_, ok := n.(localNotifier)
+	return ok
+}
+
+// optionalNotifiers build the notifiers of sinks compiled in with build tags, such as kafka. Each returns
+// nil if its sink is not configured.
+var optionalNotifiers []func() (notifier, error)
+
+// notifyAll sends an alert to each notifier, returning any delivery errors, including errHeld for alerts held
+// back for a flood summary
+func notifyAll(ctx context.Context, ns []notifier, al *alert) []error {
+	errs := []error{}
+	for _, n := range ns {
+		if err := n.Notify(ctx, al); err != nil {
+			errs = append(errs, err)
+			if !errors.Is(err, errHeld) {
+				log.Printf("notify failed: %v", err)
+			}
+		}
+	}
+	return errs
+}
+
+// pausedNotifier logs alerts instead of delivering them, while --pause-file exists
+type pausedNotifier struct{}
+
+func (pausedNotifier) Notify(_ context.Context, al *alert) error {
+	log.Printf("[paused] %s", al)
+	return nil
+}
+
+// firstRunNotifier logs alerts instead of delivering them, with --first-run
+type firstRunNotifier struct{}
+
+func (firstRunNotifier) Notify(_ context.Context, al *alert) error {
+	log.Printf("[first run] %s", al)
+	return nil
+}
+
+// checkPaused reports whether pauseFile exists, logging when alerting is paused or resumed.
+// Whether the last run was paused is tracked in statePath.
+func checkPaused(pauseFile string, statePath string) bool {
+	_, err := os.Stat(pauseFile)
+	paused := err == nil
+	_, err = os.Stat(statePath)
+	wasPaused := err == nil
+
+	switch {
+	case paused && !wasPaused:
+		log.Printf("PAUSED: %s exists, so alerts will be logged but not sent until it is removed", pauseFile)
+		if err := writeFileAtomic(statePath, nil); err != nil {
+			log.Printf("save pause state: %v", err)
+		}
+	case paused:
+		log.Printf("still paused: %s exists, so alerts will be logged but not sent", pauseFile)
+	case wasPaused:
+		log.Printf("RESUMED: %s was removed, so alerts will be sent again", pauseFile)
+		if err := os.Remove(statePath); err != nil {
+			log.Printf("save pause state: %v", err)
+		}
+	}
+	return paused
+}
+
+// slackNotifier posts alerts to a Slack incoming webhook
+type slackNotifier struct {
+	url string
+}
+
+func (n slackNotifier) Notify(_ context.Context, al *alert) error {
+	return notify(n.url, slackText(al))
+}
+
+// slackText renders an alert for Slack, mentioning the channel if the alert asks to
+func slackText(al *alert) string {
+	if al.Mention {
+		return "<!channel> " + al.String()
+	}
+	return al.String()
 }
 
 func notify(url string, text string) error {
@@ -421,7 +3053,7 @@ func notify(url string, text string) error {
 	}
 
 	log.Printf("[webhook post] %s", text)
-	return slack.PostWebhook(url, &slack.WebhookMessage{
+	return slack.PostWebhookCustomHTTP(url, webhookClient, &slack.WebhookMessage{
 		Text: text,
 	})
 }
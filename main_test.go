@@ -0,0 +1,1440 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// testOrg is the organization the audit entries in tests belong to
+const testOrg = "acme"
+
+// testNow is the time tests run at; their windows end here
+var testNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// ago returns the time d before testNow
+func ago(d time.Duration) time.Time {
+	return testNow.Add(-d)
+}
+
+// entry returns an audit entry by actor about repo, which is bare, at the given time
+func entry(action string, actor string, repo string, at time.Time) *github.AuditEntry {
+	a := &github.AuditEntry{
+		Action:    github.String(action),
+		Actor:     github.String(actor),
+		Org:       github.String(testOrg),
+		Timestamp: &github.Timestamp{Time: at},
+	}
+	if repo != "" {
+		a.Repo = github.String(testOrg + "/" + repo)
+		a.Repository = github.String(testOrg + "/" + repo)
+	}
+	a.DocumentID = github.String(fmt.Sprintf("%s/%s/%s/%d", action, actor, repo, at.UnixNano()))
+	return a
+}
+
+// clone returns a git.clone entry of a private repo
+func clone(actor string, repo string, at time.Time) *github.AuditEntry {
+	a := entry("git.clone", actor, repo, at)
+	a.RepositoryPublic = github.Bool(false)
+	return a
+}
+
+// auditServer serves entries as the test organization's audit log, newest first, and returns a client for it.
+// Like GitHub, it filters by the include parameter, and by the phrase's created:>= qualifier if honorPhrase is set.
+// The phrases it was queried with are recorded in phrases.
+func auditServer(t *testing.T, entries []*github.AuditEntry, honorPhrase bool) (c *github.Client, phrases *[]string) {
+	t.Helper()
+	*orgFlag = testOrg
+	phrases = &[]string{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/"+testOrg+"/audit-log", func(w http.ResponseWriter, r *http.Request) {
+		phrase := r.URL.Query().Get("phrase")
+		*phrases = append(*phrases, phrase)
+
+		var since time.Time
+		for _, term := range strings.Fields(phrase) {
+			if v, ok := strings.CutPrefix(term, "created:>="); ok && honorPhrase {
+				var err error
+				if since, err = time.Parse(time.RFC3339, v); err != nil {
+					t.Errorf("phrase %q: %v", phrase, err)
+				}
+			}
+		}
+
+		include := r.URL.Query().Get("include")
+		out := []*github.AuditEntry{}
+		for _, e := range entries {
+			git := strings.HasPrefix(e.GetAction(), "git.")
+			if (include == "web" && git) || (include == "git" && !git) || e.GetTimestamp().Before(since) {
+				continue
+			}
+			out = append(out, e)
+		}
+		sort.SliceStable(out, func(i, j int) bool { return out[i].GetTimestamp().After(out[j].GetTimestamp().Time) })
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			t.Errorf("encode: %v", err)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	c = github.NewClient(nil)
+	c.BaseURL, _ = url.Parse(srv.URL + "/")
+	return c, phrases
+}
+
+// actions returns "actor action repo" for each entry, for comparing results
+func actions(es []*github.AuditEntry) []string {
+	out := []string{}
+	for _, e := range es {
+		out = append(out, strings.TrimSpace(fmt.Sprintf("%s %s %s", e.GetActor(), e.GetAction(), e.GetRepo())))
+	}
+	return out
+}
+
+// actors returns the distinct actors of entries, sorted
+func actors(es []*github.AuditEntry) []string {
+	out := []string{}
+	for _, e := range es {
+		if !slices.Contains(out, e.GetActor()) {
+			out = append(out, e.GetActor())
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func equalStrings(a []string, b []string) bool {
+	return strings.Join(a, "\n") == strings.Join(b, "\n")
+}
+
+func TestValidateWindows(t *testing.T) {
+	for _, tc := range []struct {
+		interval, cloneInterval time.Duration
+		wantErr                 bool
+	}{
+		{time.Hour, 4 * time.Hour, false},
+		{time.Hour, time.Hour, false},
+		{2 * time.Hour, time.Hour, true},
+		{0, time.Hour, false},
+	} {
+		err := validateWindows(tc.interval, tc.cloneInterval)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("validateWindows(%s, %s) = %v, want error %v", tc.interval, tc.cloneInterval, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCloneEventsWindows(t *testing.T) {
+	// Clones before the alert window count towards the threshold, but are not alerted on themselves
+	entries := []*github.AuditEntry{
+		clone("alice", "one", ago(3*time.Hour)),
+		clone("alice", "two", ago(2*time.Hour)),
+		clone("alice", "three", ago(30*time.Minute)),
+		// Only one clone within the grouping window
+		clone("bob", "one", ago(5*time.Hour)),
+		clone("bob", "two", ago(5*time.Hour)),
+		clone("bob", "three", ago(10*time.Minute)),
+	}
+	c, _ := auditServer(t, entries, true)
+
+	for _, tc := range []struct {
+		name           string
+		cloneInterval  time.Duration
+		interval       time.Duration
+		want           []string
+		wantValidation bool
+	}{
+		{
+			name:          "grouping window covers earlier clones",
+			cloneInterval: 4 * time.Hour,
+			interval:      time.Hour,
+			want:          []string{"alice git.clone acme/three"},
+		},
+		{
+			name:          "grouping window equal to the alert window",
+			cloneInterval: time.Hour,
+			interval:      time.Hour,
+			want:          []string{},
+		},
+		{
+			name:          "grouping window covers every clone",
+			cloneInterval: 6 * time.Hour,
+			interval:      time.Hour,
+			want:          []string{"alice git.clone acme/three", "bob git.clone acme/three"},
+		},
+		{
+			name:           "alert window larger than the grouping window",
+			cloneInterval:  time.Hour,
+			interval:       2 * time.Hour,
+			wantValidation: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateWindows(tc.interval, tc.cloneInterval); (err != nil) != tc.wantValidation {
+				t.Fatalf("validateWindows = %v, want error %v", err, tc.wantValidation)
+			}
+			if tc.wantValidation {
+				return
+			}
+
+			s := Settings{
+				Org:            testOrg,
+				Since:          ago(tc.interval),
+				MaxClonesSince: ago(tc.cloneInterval),
+				ClonesInclude:  "git",
+				MaxClonedRepos: 3,
+			}
+			got, err := cloneEvents(context.Background(), c, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalStrings(actions(got), tc.want) {
+				t.Errorf("cloneEvents = %q, want %q", actions(got), tc.want)
+			}
+		})
+	}
+}
+
+// fakeNotifier records the alerts it is sent, failing those that fail matches
+type fakeNotifier struct {
+	sent []*alert
+	fail func(al *alert) bool
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, al *alert) error {
+	if n.fail != nil && n.fail(al) {
+		return fmt.Errorf("failed to deliver %q", al.Message)
+	}
+	n.sent = append(n.sent, al)
+	return nil
+}
+
+func TestRunStats(t *testing.T) {
+	ok := &fakeNotifier{}
+	flaky := &fakeNotifier{fail: func(al *alert) bool { return strings.Contains(al.Message, "flaky") }}
+	down := &fakeNotifier{fail: func(*alert) bool { return true }}
+
+	for _, tc := range []struct {
+		name     string
+		ns       []notifier
+		messages []string
+		want     string
+		lastErr  string
+	}{
+		{
+			name:     "all delivered",
+			ns:       []notifier{ok},
+			messages: []string{"one", "two"},
+			want:     "0 events scanned, 2 alerts attempted, 2 delivered, 0 delivery failures",
+		},
+		{
+			name:     "one sink fails one alert",
+			ns:       []notifier{ok, flaky},
+			messages: []string{"one", "flaky two", "three"},
+			want:     "0 events scanned, 3 alerts attempted, 2 delivered, 1 delivery failures",
+			lastErr:  `failed to deliver "flaky two"`,
+		},
+		{
+			name:     "every sink fails",
+			ns:       []notifier{down, flaky},
+			messages: []string{"flaky one", "flaky two"},
+			want:     "0 events scanned, 2 alerts attempted, 0 delivered, 4 delivery failures",
+			lastErr:  `failed to deliver "flaky two"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := &runStats{}
+			for _, m := range tc.messages {
+				stats.notify(context.Background(), tc.ns, newAlert(nil, "", m, repoSet{}))
+			}
+			if got := stats.String(); got != tc.want {
+				t.Errorf("stats = %q, want %q", got, tc.want)
+			}
+			lastErr := ""
+			if stats.lastErr != nil {
+				lastErr = stats.lastErr.Error()
+			}
+			if lastErr != tc.lastErr {
+				t.Errorf("last error = %q, want %q", lastErr, tc.lastErr)
+			}
+		})
+	}
+}
+
+func TestDeadLetterWithOutput(t *testing.T) {
+	down := &fakeNotifier{fail: func(*alert) bool { return true }}
+	ok := &fakeNotifier{}
+	var out bytes.Buffer
+	output := redactor{n: siemNotifier{w: &out, format: "cef"}}
+
+	path := filepath.Join(t.TempDir(), "dead-letters")
+	stats := &runStats{deadLetters: &deadLetters{path: path, maxBytes: 1 << 20}}
+	// Local output does not count as delivery, so these are undelivered
+	stats.notify(context.Background(), []notifier{down, output}, newAlert(nil, "", "one", repoSet{}))
+	stats.notify(context.Background(), []notifier{down, down, output}, newAlert(nil, "", "two", repoSet{}))
+	// Delivered by a remote sink
+	stats.notify(context.Background(), []notifier{down, ok, output}, newAlert(nil, "", "three", repoSet{}))
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		al := &alert{}
+		if err := json.Unmarshal([]byte(line), al); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, al.Message)
+	}
+	if want := []string{"one", "two"}; !equalStrings(got, want) {
+		t.Errorf("dead letters = %q, want %q", got, want)
+	}
+	if n := strings.Count(out.String(), "CEF:"); n != 3 {
+		t.Errorf("%d alerts written to the output, want 3", n)
+	}
+}
+
+func TestTokenPrecursor(t *testing.T) {
+	web := []*github.AuditEntry{
+		entry("personal_access_token.request_created", "alice", "", ago(3*time.Hour)),
+		// Grants are made by an admin, on behalf of the token's owner
+		func() *github.AuditEntry {
+			e := entry("personal_access_token.access_granted", "admin", "", ago(90*time.Minute))
+			e.User = github.String("bob")
+			return e
+		}(),
+		entry("personal_access_token.request_created", "carol", "", ago(20*time.Minute)),
+		entry("repo.create", "dave", "new", ago(30*time.Minute)),
+		// Before the clone window and its token window
+		entry("personal_access_token.request_created", "erin", "", ago(10*time.Hour)),
+	}
+	s := Settings{MaxClonesSince: ago(4 * time.Hour), TokenCloneWindow: time.Hour}
+	created := tokenCreations(web, s)
+
+	for _, tc := range []struct {
+		clone *github.AuditEntry
+		want  time.Time
+		ok    bool
+	}{
+		// Too long after the token
+		{clone("alice", "one", ago(time.Hour)), time.Time{}, false},
+		{clone("alice", "one", ago(150*time.Minute)), ago(3 * time.Hour), true},
+		// Joined by the token's owner, not the admin who granted it
+		{clone("bob", "one", ago(time.Hour)), ago(90 * time.Minute), true},
+		{clone("admin", "one", ago(time.Hour)), time.Time{}, false},
+		// Before the token was created
+		{clone("carol", "one", ago(30*time.Minute)), time.Time{}, false},
+		{clone("carol", "one", ago(10*time.Minute)), ago(20 * time.Minute), true},
+		{clone("dave", "one", ago(10*time.Minute)), time.Time{}, false},
+		{clone("erin", "one", ago(9*time.Hour)), time.Time{}, false},
+	} {
+		got, ok := tokenPrecursor(tc.clone, created, s.TokenCloneWindow)
+		if ok != tc.ok || !got.Equal(tc.want) {
+			t.Errorf("tokenPrecursor(%s at %s) = %s, %v; want %s, %v", tc.clone.GetActor(), tc.clone.GetTimestamp(), got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestAuditLogSince(t *testing.T) {
+	entries := []*github.AuditEntry{
+		entry("repo.create", "alice", "old", ago(3*time.Hour)),
+		entry("repo.destroy", "alice", "old", ago(2*time.Hour)),
+		entry("repo.create", "bob", "new", ago(30*time.Minute)),
+		entry("org.add_member", "carol", "", ago(10*time.Minute)),
+		clone("dave", "new", ago(5*time.Minute)),
+	}
+	since := ago(time.Hour)
+
+	var want []string
+	for _, honorPhrase := range []bool{true, false} {
+		c, phrases := auditServer(t, entries, honorPhrase)
+		got, err := auditLog(context.Background(), c, "web", since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sortEntries(got)
+		if want == nil {
+			want = actions(got)
+		}
+		// Whether or not GitHub applies the time filter, the same entries are returned
+		if !equalStrings(actions(got), want) || len(got) != 2 {
+			t.Errorf("honorPhrase %v: auditLog = %q, want %q", honorPhrase, actions(got), want)
+		}
+		for _, p := range *phrases {
+			if !strings.Contains(p, "created:>="+since.Format(time.RFC3339)) {
+				t.Errorf("phrase %q does not filter on time", p)
+			}
+		}
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	es := []*github.AuditEntry{
+		entry("repo.destroy", "bob", "one", ago(time.Minute)),
+		entry("repo.create", "bob", "one", ago(time.Minute)),
+		entry("repo.create", "alice", "two", ago(time.Minute)),
+		entry("repo.create", "carol", "three", ago(time.Hour)),
+	}
+	want := []string{
+		"carol repo.create acme/three",
+		"alice repo.create acme/two",
+		"bob repo.create acme/one",
+		"bob repo.destroy acme/one",
+	}
+	// Every starting order sorts the same way
+	for i := range es {
+		shuffled := append(append([]*github.AuditEntry{}, es[i:]...), es[:i]...)
+		sortEntries(shuffled)
+		if !equalStrings(actions(shuffled), want) {
+			t.Errorf("rotation %d: sortEntries = %q, want %q", i, actions(shuffled), want)
+		}
+	}
+}
+
+func TestEventOrdering(t *testing.T) {
+	entries := []*github.AuditEntry{
+		entry("repo.destroy", "bob", "one", ago(time.Minute)),
+		entry("repo.create", "bob", "one", ago(time.Minute)),
+		entry("repo.create", "alice", "two", ago(time.Minute)),
+		entry("repo.create", "carol", "three", ago(30*time.Minute)),
+	}
+	for _, u := range []string{"zed", "alice", "mallory"} {
+		for i, r := range []string{"one", "two", "three"} {
+			entries = append(entries, clone(u, r, ago(time.Duration(i)*time.Minute)))
+		}
+	}
+	c, _ := auditServer(t, entries, true)
+	s := Settings{
+		Org:                      testOrg,
+		Since:                    ago(time.Hour),
+		MaxClonesSince:           ago(time.Hour),
+		ClonesInclude:            "git",
+		MaxClonedRepos:           3,
+		GlobalIgnoreActions:      []string{"org.update_member"},
+		NonCriticalIgnoreActions: []string{"repo.archived"},
+	}
+
+	wantWeb := []string{
+		"carol repo.create acme/three",
+		"alice repo.create acme/two",
+		"bob repo.create acme/one",
+		"bob repo.destroy acme/one",
+	}
+	wantClones := []string{
+		"alice git.clone acme/three", "mallory git.clone acme/three", "zed git.clone acme/three",
+		"alice git.clone acme/two", "mallory git.clone acme/two", "zed git.clone acme/two",
+		"alice git.clone acme/one", "mallory git.clone acme/one", "zed git.clone acme/one",
+	}
+	// Map iteration order varies between runs, so check several
+	for i := 0; i < 10; i++ {
+		if got := webEvents(entries, s); !equalStrings(actions(got), wantWeb) {
+			t.Fatalf("webEvents = %q, want %q", actions(got), wantWeb)
+		}
+		got, err := cloneEvents(context.Background(), c, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalStrings(actions(got), wantClones) {
+			t.Fatalf("cloneEvents = %q, want %q", actions(got), wantClones)
+		}
+	}
+}
+
+func TestIncludePublicClones(t *testing.T) {
+	public := func(actor string, repo string, at time.Time) *github.AuditEntry {
+		a := clone(actor, repo, at)
+		a.RepositoryPublic = github.Bool(true)
+		return a
+	}
+	entries := []*github.AuditEntry{
+		clone("alice", "one", ago(30*time.Minute)),
+		clone("alice", "two", ago(20*time.Minute)),
+		public("alice", "docs", ago(10*time.Minute)),
+		public("bob", "docs", ago(30*time.Minute)),
+		public("bob", "website", ago(20*time.Minute)),
+		public("bob", "sdk", ago(10*time.Minute)),
+	}
+	c, _ := auditServer(t, entries, true)
+
+	for _, tc := range []struct {
+		include bool
+		want    []string
+	}{
+		// Neither reaches three private repos
+		{false, []string{}},
+		{true, []string{
+			"alice git.clone acme/one",
+			"bob git.clone acme/docs",
+			"alice git.clone acme/two",
+			"bob git.clone acme/website",
+			"alice git.clone acme/docs",
+			"bob git.clone acme/sdk",
+		}},
+	} {
+		s := Settings{
+			Org:                 testOrg,
+			Since:               ago(time.Hour),
+			MaxClonesSince:      ago(time.Hour),
+			ClonesInclude:       "git",
+			MaxClonedRepos:      3,
+			IncludePublicClones: tc.include,
+		}
+		got, err := cloneEvents(context.Background(), c, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalStrings(actions(got), tc.want) {
+			t.Errorf("IncludePublicClones %v: cloneEvents = %q, want %q", tc.include, actions(got), tc.want)
+		}
+	}
+}
+
+func TestRepoSet(t *testing.T) {
+	rs, err := normalizeRepos(testOrg, []string{" Widgets ", "*-prod", "other/Infra", "svc-?", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		repo string
+		want bool
+	}{
+		{"acme/widgets", true},
+		{"ACME/Widgets", true},
+		{"acme/widgets-dev", false},
+		{"acme/api-prod", true},
+		{"acme/API-PROD", true},
+		{"acme/prod", false},
+		{"other/api-prod", false},
+		{"other/infra", true},
+		{"acme/infra", false},
+		{"acme/svc-a", true},
+		{"acme/svc-ab", false},
+		{"", false},
+	} {
+		if got := rs.has(tc.repo); got != tc.want {
+			t.Errorf("has(%q) = %v, want %v", tc.repo, got, tc.want)
+		}
+	}
+
+	if _, err := normalizeRepos(testOrg, []string{"[-prod"}); err == nil {
+		t.Error("normalizeRepos accepted a malformed pattern")
+	}
+}
+
+func TestCriticalRepoPatterns(t *testing.T) {
+	critical, err := normalizeRepos(testOrg, []string{"widgets", "*-prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Settings{
+		Org:                      testOrg,
+		Since:                    ago(time.Hour),
+		CriticalRepos:            critical,
+		GlobalIgnoreActions:      []string{"org.update_member"},
+		NonCriticalIgnoreActions: []string{"repo.add_topic"},
+	}
+	entries := []*github.AuditEntry{
+		entry("repo.add_topic", "alice", "widgets", ago(30*time.Minute)),
+		entry("repo.add_topic", "alice", "api-prod", ago(20*time.Minute)),
+		entry("repo.add_topic", "alice", "api-dev", ago(10*time.Minute)),
+	}
+	want := []string{"alice repo.add_topic acme/widgets", "alice repo.add_topic acme/api-prod"}
+	if got := webEvents(entries, s); !equalStrings(actions(got), want) {
+		t.Errorf("webEvents = %q, want %q", actions(got), want)
+	}
+}
+
+func TestGrantBurst(t *testing.T) {
+	grant := func(action string, actor string, user string, repo string, at time.Time) *github.AuditEntry {
+		a := entry(action, actor, repo, at)
+		a.User = github.String(user)
+		return a
+	}
+	audit := []*github.AuditEntry{
+		// Several admins grant mallory access, which no one actor's grants would show
+		grant("repo.add_member", "admin1", "mallory", "one", ago(50*time.Minute)),
+		grant("repo.add_member", "admin2", "mallory", "two", ago(40*time.Minute)),
+		grant("repo.update_member", "admin3", "mallory", "three", ago(30*time.Minute)),
+		// One admin grants many users one repo each
+		grant("repo.add_member", "admin1", "alice", "one", ago(20*time.Minute)),
+		grant("repo.add_member", "admin1", "bob", "two", ago(20*time.Minute)),
+		grant("repo.add_member", "admin1", "carol", "three", ago(20*time.Minute)),
+		// Repeated grants of the same repo count once
+		grant("repo.add_member", "admin1", "dave", "one", ago(30*time.Minute)),
+		grant("repo.update_member", "admin2", "dave", "one", ago(20*time.Minute)),
+		grant("repo.update_member", "admin3", "dave", "one", ago(10*time.Minute)),
+		// Too spread out for the window
+		grant("repo.add_member", "admin1", "erin", "one", ago(5*time.Hour)),
+		grant("repo.add_member", "admin1", "erin", "two", ago(3*time.Hour)),
+		grant("org.add_member", "admin1", "erin", "", ago(10*time.Minute)),
+	}
+	s := Settings{Org: testOrg, Since: ago(time.Hour), MaxGrantsPerUser: 3, GrantBurstWindow: time.Hour}
+	want := []string{
+		"admin1 repo.add_member acme/one",
+		"admin2 repo.add_member acme/two",
+		"admin3 repo.update_member acme/three",
+	}
+	if got := burstEvents(audit, s, grantBurst(s)); !equalStrings(actions(got), want) {
+		t.Errorf("burstEvents = %q, want %q", actions(got), want)
+	}
+}
+
+func TestRepoCreationBurst(t *testing.T) {
+	deleted := func(repo string, at time.Time) *github.AuditEntry {
+		a := entry("repo.create", "", repo, at)
+		a.Actor = nil
+		return a
+	}
+	entries := []*github.AuditEntry{
+		entry("repo.create", "alice", "one", ago(50*time.Minute)),
+		entry("repo.create", "alice", "two", ago(40*time.Minute)),
+		entry("repo.create", "alice", "three", ago(30*time.Minute)),
+		// Too few
+		entry("repo.create", "bob", "four", ago(30*time.Minute)),
+		entry("repo.create", "bob", "five", ago(20*time.Minute)),
+		// Too spread out for the window
+		entry("repo.create", "carol", "six", ago(5*time.Hour)),
+		entry("repo.create", "carol", "seven", ago(3*time.Hour)),
+		entry("repo.create", "carol", "eight", ago(10*time.Minute)),
+		// Deleted accounts are grouped together rather than ignored
+		deleted("nine", ago(25*time.Minute)),
+		deleted("ten", ago(15*time.Minute)),
+		deleted("eleven", ago(5*time.Minute)),
+	}
+	c, _ := auditServer(t, entries, false)
+	s := Settings{Org: testOrg, Since: ago(time.Hour), MaxReposCreated: 3, RepoCreationBurstWindow: time.Hour}
+	web, err := auditLog(context.Background(), c, "web", webLogSince(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"alice repo.create acme/one",
+		"alice repo.create acme/two",
+		"alice repo.create acme/three",
+		"repo.create acme/nine",
+		"repo.create acme/ten",
+		"repo.create acme/eleven",
+	}
+	if got := burstEvents(web, s, repoCreationBurst(s)); !equalStrings(actions(got), want) {
+		t.Errorf("burstEvents = %q, want %q", actions(got), want)
+	}
+}
+
+func TestMaxDistinctInWindow(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		events []*github.AuditEntry
+		window time.Duration
+		want   int
+	}{
+		{"none", nil, time.Hour, 0},
+		{"repeats count once", []*github.AuditEntry{
+			clone("alice", "one", ago(3*time.Minute)),
+			clone("alice", "one", ago(2*time.Minute)),
+			clone("alice", "one", ago(time.Minute)),
+		}, time.Hour, 1},
+		{"out of order", []*github.AuditEntry{
+			clone("alice", "three", ago(time.Minute)),
+			clone("alice", "one", ago(3*time.Minute)),
+			clone("alice", "two", ago(2*time.Minute)),
+		}, time.Hour, 3},
+		{"sliding", []*github.AuditEntry{
+			clone("alice", "one", ago(5*time.Hour)),
+			clone("alice", "two", ago(4*time.Hour)),
+			clone("alice", "three", ago(150*time.Minute)),
+			clone("alice", "four", ago(2*time.Hour)),
+			clone("alice", "five", ago(90*time.Minute)),
+		}, time.Hour, 3},
+		{"window edge is inclusive", []*github.AuditEntry{
+			clone("alice", "one", ago(2*time.Hour)),
+			clone("alice", "two", ago(time.Hour)),
+		}, time.Hour, 2},
+		{"an early repo repeated inside the window", []*github.AuditEntry{
+			clone("alice", "one", ago(3*time.Hour)),
+			clone("alice", "two", ago(30*time.Minute)),
+			clone("alice", "one", ago(20*time.Minute)),
+		}, time.Hour, 2},
+	} {
+		if got := maxDistinctInWindow(tc.events, tc.window, auditLocation); got != tc.want {
+			t.Errorf("%s: maxDistinctInWindow = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestActionRegexp(t *testing.T) {
+	for _, tc := range []struct {
+		patterns []string
+		action   string
+		want     bool
+	}{
+		{[]string{"repo.create"}, "repo.create", true},
+		{[]string{"repo.create"}, "Repo.Create", true},
+		{[]string{"REPO.CREATE"}, "repo.create", true},
+		{[]string{"repo.create"}, "repo.created", false},
+		{[]string{"repo.create"}, "org.repo.create", false},
+		{[]string{"repo.*", "org.add_member"}, "Repo.Destroy", true},
+		{[]string{"repo.*", "org.add_member"}, "ORG.add_MEMBER", true},
+		{[]string{"repo.*", "org.add_member"}, "org.remove_member", false},
+		{nil, "repo.create", false},
+		{nil, "", false},
+	} {
+		if got := actionRegexp(tc.patterns).MatchString(tc.action); got != tc.want {
+			t.Errorf("actionRegexp(%q) matches %q = %v, want %v", tc.patterns, tc.action, got, tc.want)
+		}
+	}
+}
+
+func TestEmojiFor(t *testing.T) {
+	for action, want := range map[string]string{
+		"git.clone":                 "📦",
+		"Git.Clone":                 "📦",
+		"org.update_actions_secret": "🤫",
+		"ORG.ADD_MEMBER":            "👤",
+		"repo.add_deploy_key":       "🔑",
+		"repo.add_deploy_keys":      defaultEmoji,
+		"business.set_payment_plan": defaultEmoji,
+	} {
+		if got := emojiFor(action); got != want {
+			t.Errorf("emojiFor(%q) = %q, want %q", action, got, want)
+		}
+	}
+}
+
+func TestWebEventsMixedCaseActions(t *testing.T) {
+	s := Settings{
+		Org:                      testOrg,
+		Since:                    ago(time.Hour),
+		GlobalIgnoreActions:      []string{"org.update_member"},
+		NonCriticalIgnoreActions: []string{"repo.add_topic"},
+	}
+	entries := []*github.AuditEntry{
+		entry("Org.Update_Member", "alice", "", ago(30*time.Minute)),
+		entry("REPO.ADD_TOPIC", "alice", "widgets", ago(20*time.Minute)),
+		entry("Repo.Destroy", "alice", "widgets", ago(10*time.Minute)),
+	}
+	want := []string{"alice Repo.Destroy acme/widgets"}
+	if got := webEvents(entries, s); !equalStrings(actions(got), want) {
+		t.Errorf("webEvents = %q, want %q", actions(got), want)
+	}
+
+	// Without ignore lists, nothing is ignored
+	if got := webEvents(entries, Settings{Org: testOrg, Since: ago(time.Hour)}); len(got) != len(entries) {
+		t.Errorf("webEvents without ignore lists = %q, want every entry", actions(got))
+	}
+}
+
+func TestRepoSeverityEscalation(t *testing.T) {
+	rs, err := parseRepoSeverities(testOrg, []string{"crown-jewels=critical", "*-prod=high", "api-prod=medium"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"crown-jewels", "crown-jewels=urgent", "=high"} {
+		if _, err := parseRepoSeverities(testOrg, []string{p}); err == nil {
+			t.Errorf("parseRepoSeverities accepted %q", p)
+		}
+	}
+
+	for _, tc := range []struct {
+		repo     string
+		severity string
+		priority string
+		mention  bool
+	}{
+		{"crown-jewels", severityCritical, "P1", true},
+		// The highest matching severity wins
+		{"api-prod", severityHigh, "P2", false},
+		{"widgets", severityMedium, "P3", false},
+	} {
+		al := newAlert(entry("repo.add_topic", "alice", tc.repo, ago(0)), "", "added a topic", repoSet{})
+		applyRepoSeverity(al, rs)
+		if al.Severity != tc.severity || opsgeniePriority[al.Severity] != tc.priority {
+			t.Errorf("%s: severity %s, priority %s; want %s, %s", tc.repo, al.Severity, opsgeniePriority[al.Severity], tc.severity, tc.priority)
+		}
+		if got := strings.HasPrefix(slackText(al), "<!channel> "); got != tc.mention {
+			t.Errorf("%s: mentioned %v, want %v: %s", tc.repo, got, tc.mention, slackText(al))
+		}
+
+		// Critical alerts are exempt from throttling
+		th := &throttle{max: 0}
+		if got := th.allow(al); got != (tc.severity == severityCritical) {
+			t.Errorf("%s: throttle allowed %v", tc.repo, got)
+		}
+	}
+
+	// Severities are never lowered
+	critical, _ := normalizeRepos(testOrg, []string{"api-prod"})
+	al := newAlert(entry("repo.add_topic", "alice", "api-prod", ago(0)), "", "added a topic", critical)
+	applyRepoSeverity(al, rs)
+	if al.Severity != severityCritical {
+		t.Errorf("critical repo lowered to %s", al.Severity)
+	}
+}
+
+func TestWorkflowPermsEscalation(t *testing.T) {
+	perms := func(action string, permission string, old string) *github.AuditEntry {
+		a := entry(action, "alice", "widgets", ago(time.Minute))
+		if permission != "" {
+			a.Permission = github.String(permission)
+		}
+		if old != "" {
+			a.OldPermission = github.String(old)
+		}
+		return a
+	}
+	approve := func(action string, active *bool, was *bool) *github.AuditEntry {
+		a := entry(action, "alice", "widgets", ago(time.Minute))
+		a.Active, a.ActiveWas = active, was
+		return a
+	}
+	on, off := github.Bool(true), github.Bool(false)
+
+	for _, tc := range []struct {
+		name string
+		a    *github.AuditEntry
+		want bool
+	}{
+		{"default to write", perms("repo.set_default_workflow_permissions", "write", "read"), true},
+		{"org default to write", perms("org.set_default_workflow_permissions", "write", ""), true},
+		{"default to read", perms("repo.set_default_workflow_permissions", "read", "write"), false},
+		{"default unchanged", perms("repo.set_default_workflow_permissions", "write", "write"), false},
+		{"default not recorded", perms("repo.set_default_workflow_permissions", "", ""), false},
+		{"approval allowed", approve("repo.set_workflow_permission_can_approve_pr", on, off), true},
+		{"org approval allowed", approve("org.set_workflow_permission_can_approve_pr", on, nil), true},
+		{"approval disallowed", approve("repo.set_workflow_permission_can_approve_pr", off, on), false},
+		{"approval already allowed", approve("repo.set_workflow_permission_can_approve_pr", on, on), false},
+		{"approval not recorded", approve("repo.set_workflow_permission_can_approve_pr", nil, nil), false},
+	} {
+		if got := workflowPermsEscalation(tc.a); got != tc.want {
+			t.Errorf("%s: workflowPermsEscalation = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// De-escalations stay ignored, and escalations are surfaced with the prefix
+	s := Settings{
+		Org:                      testOrg,
+		Since:                    ago(time.Hour),
+		NonCriticalIgnoreActions: workflowPermsActions,
+		Overrides:                []*override{newOverride("workflow-perms", workflowPermsActions, workflowPermsEscalation)},
+	}
+	entries := []*github.AuditEntry{
+		perms("repo.set_default_workflow_permissions", "write", "read"),
+		perms("repo.set_default_workflow_permissions", "read", "write"),
+		approve("repo.set_workflow_permission_can_approve_pr", off, on),
+	}
+	want := []string{"alice repo.set_default_workflow_permissions acme/widgets"}
+	if got := webEvents(entries, s); !equalStrings(actions(got), want) {
+		t.Errorf("webEvents = %q, want %q", actions(got), want)
+	}
+}
+
+func TestCollapseVisibilityFlips(t *testing.T) {
+	vis := func(repo, from, to string, at time.Time) *github.AuditEntry {
+		e := entry("repo.access", "alice", repo, at)
+		e.PreviousVisibility = github.String(from)
+		e.Visibility = github.String(to)
+		return e
+	}
+	changes := func(es []*github.AuditEntry) []string {
+		out := []string{}
+		for _, e := range es {
+			out = append(out, strings.TrimSpace(fmt.Sprintf("%s %s %s->%s", e.GetAction(), e.GetRepo(), e.GetPreviousVisibility(), e.GetVisibility())))
+		}
+		return out
+	}
+
+	flip := []*github.AuditEntry{
+		vis("widgets", "public", "private", ago(50*time.Minute)),
+		entry("repo.create", "alice", "gadgets", ago(40*time.Minute)),
+		vis("widgets", "private", "public", ago(30*time.Minute)),
+		vis("gizmos", "private", "public", ago(20*time.Minute)),
+	}
+	for _, tc := range []struct {
+		name    string
+		entries []*github.AuditEntry
+		mode    string
+		want    []string
+	}{
+		{
+			name:    "flip collapses into its last change",
+			entries: flip,
+			mode:    "collapse",
+			want: []string{
+				"repo.create acme/gadgets ->",
+				"repo.access acme/widgets private->public",
+				"repo.access acme/gizmos private->public",
+			},
+		},
+		{
+			name:    "flip suppressed",
+			entries: flip,
+			mode:    "suppress",
+			want: []string{
+				"repo.create acme/gadgets ->",
+				"repo.access acme/gizmos private->public",
+			},
+		},
+		{
+			name: "changes ending elsewhere all alert",
+			entries: []*github.AuditEntry{
+				vis("widgets", "public", "private", ago(50*time.Minute)),
+				vis("widgets", "private", "internal", ago(30*time.Minute)),
+			},
+			mode: "collapse",
+			want: []string{
+				"repo.access acme/widgets public->private",
+				"repo.access acme/widgets private->internal",
+			},
+		},
+	} {
+		if got := changes(collapseVisibilityFlips(tc.entries, tc.mode)); !equalStrings(got, tc.want) {
+			t.Errorf("%s: collapseVisibilityFlips = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// Only changes within the window are paired, so a flip that started before it alerts on its own
+	s := Settings{Org: testOrg, Since: ago(time.Hour), VisibilityFlips: "collapse"}
+	entries := []*github.AuditEntry{
+		vis("widgets", "public", "private", ago(2*time.Hour)),
+		vis("widgets", "private", "public", ago(30*time.Minute)),
+		vis("gizmos", "public", "private", ago(40*time.Minute)),
+		vis("gizmos", "private", "public", ago(20*time.Minute)),
+	}
+	want := []string{
+		"repo.access acme/widgets private->public",
+		"repo.access acme/gizmos private->public",
+	}
+	if got := changes(webEvents(entries, s)); !equalStrings(got, want) {
+		t.Errorf("webEvents = %q, want %q", got, want)
+	}
+}
+
+func TestAlertRaise(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		want     string
+		mention  bool
+	}{
+		{severityMedium, severityHigh, severityHigh, false},
+		{severityMedium, severityCritical, severityCritical, true},
+		{severityHigh, severityCritical, severityCritical, true},
+		// Severities are never lowered, and raising to the same level changes nothing
+		{severityHigh, severityMedium, severityHigh, false},
+		{severityCritical, severityCritical, severityCritical, false},
+	} {
+		al := &alert{Entry: entry("repo.destroy", "alice", "widgets", ago(0)), Severity: tc.from}
+		al.raise(tc.to)
+		if al.Severity != tc.want || al.Mention != tc.mention {
+			t.Errorf("raise %s to %s: severity %s, mention %v; want %s, %v", tc.from, tc.to, al.Severity, al.Mention, tc.want, tc.mention)
+		}
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	patterns, err := parseBotPatterns([]string{`ci-runner-\w+`, "release-bot", "deploy|sync"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"[bot]", "-robot", ""}
+
+	for _, tc := range []struct {
+		login string
+		want  bool
+	}{
+		// Names are literal suffixes
+		{"dependabot[bot]", true},
+		{"acme-robot", true},
+		{"robotics", false},
+		{"b", false},
+		// Patterns match the whole login, ignoring case
+		{"ci-runner-prod", true},
+		{"CI-Runner-Staging", true},
+		{"my-ci-runner-prod", false},
+		{"ci-runner-", false},
+		{"release-bot", true},
+		{"release-bot2", false},
+		{"deploy", true},
+		{"sync", true},
+		{"deploy-sync", false},
+		{"alice", false},
+	} {
+		if got := isBot(tc.login, names, patterns); got != tc.want {
+			t.Errorf("isBot(%q) = %v, want %v", tc.login, got, tc.want)
+		}
+	}
+
+	if _, err := parseBotPatterns([]string{"ci-("}); err == nil {
+		t.Error("parseBotPatterns accepted an invalid regexp")
+	}
+}
+
+func TestAlwaysAlert(t *testing.T) {
+	filter, err := writeRepoFilter(t, "allow:\n  - widgets\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns, err := parseBotPatterns([]string{"ci-.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cidrs, err := parseCIDRs("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every filter and ignore list would otherwise skip the entry
+	s := Settings{
+		Org:                      testOrg,
+		Since:                    ago(time.Hour),
+		GlobalIgnoreActions:      []string{"org.*"},
+		NonCriticalIgnoreActions: []string{"org.disable_two_factor_requirement"},
+		RepoFilter:               filter,
+		BotNames:                 []string{"[bot]"},
+		BotPatterns:              patterns,
+		IgnoreCIDRs:              cidrs,
+	}
+	disable := func(actor string) *github.AuditEntry {
+		a := entry("Org.Disable_Two_Factor_Requirement", actor, "", ago(10*time.Minute))
+		a.ActorIP = github.String("10.1.2.3")
+		return a
+	}
+	entries := []*github.AuditEntry{
+		disable("admin[bot]"),
+		disable("ci-admin"),
+		entry("org.enable_two_factor_requirement", "alice", "", ago(5*time.Minute)),
+	}
+	want := []string{"admin[bot] Org.Disable_Two_Factor_Requirement", "ci-admin Org.Disable_Two_Factor_Requirement"}
+	got := webEvents(entries, s)
+	if !equalStrings(actions(got), want) {
+		t.Fatalf("webEvents = %q, want %q", actions(got), want)
+	}
+
+	al := newAlert(got[0], "", "disabled 2FA", repoSet{})
+	if al.Severity != severityCritical {
+		t.Errorf("severity = %s, want critical", al.Severity)
+	}
+	if !strings.HasPrefix(slackText(al), "<!channel> ") {
+		t.Errorf("no mention: %s", slackText(al))
+	}
+	if al := newAlert(entries[2], "", "enabled 2FA", repoSet{}); al.Severity == severityCritical || strings.Contains(slackText(al), "<!channel>") {
+		t.Errorf("enabling 2FA alerted as %s: %s", al.Severity, slackText(al))
+	}
+}
+
+func TestCloneDensity(t *testing.T) {
+	minutes := func(ms ...int) []time.Time {
+		times := []time.Time{}
+		for _, m := range ms {
+			times = append(times, ago(2*time.Hour).Add(time.Duration(m)*time.Minute))
+		}
+		return times
+	}
+	for _, tc := range []struct {
+		name  string
+		times []time.Time
+		want  float64
+	}{
+		{"none", nil, 0},
+		{"one clone", minutes(0), 0},
+		{"within a minute counts as a minute", minutes(0, 0, 0), 3},
+		{"slow", minutes(0, 30, 60), 2.0 / 30},
+		{"fast burst among slow clones", minutes(0, 60, 61, 62, 63, 120), 2},
+		{"every clone a minute apart", minutes(0, 1, 2, 3, 4), 2},
+	} {
+		if got := cloneDensity(tc.times); got < tc.want-1e-9 || got > tc.want+1e-9 {
+			t.Errorf("%s: cloneDensity = %f, want %f", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFirstClones(t *testing.T) {
+	events := []*github.AuditEntry{
+		clone("alice", "two", ago(10*time.Minute)),
+		clone("alice", "one", ago(5*time.Minute)),
+		clone("alice", "one", ago(20*time.Minute)),
+		// A fork counts as the same repo
+		clone("alice", "../fork/two", ago(30*time.Minute)),
+	}
+	got := firstClones(events)
+	want := []time.Time{ago(30 * time.Minute), ago(20 * time.Minute)}
+	if len(got) != len(want) || !got[0].Equal(want[0]) || !got[1].Equal(want[1]) {
+		t.Errorf("firstClones = %v, want %v", got, want)
+	}
+}
+
+func TestCloneEventsDensity(t *testing.T) {
+	entries := []*github.AuditEntry{
+		// Three repos in two minutes
+		clone("alice", "one", ago(12*time.Minute)),
+		clone("alice", "two", ago(11*time.Minute)),
+		clone("alice", "three", ago(10*time.Minute)),
+		// Many repos, slowly
+		clone("bob", "one", ago(50*time.Minute)),
+		clone("bob", "two", ago(40*time.Minute)),
+		clone("bob", "three", ago(30*time.Minute)),
+		clone("bob", "four", ago(20*time.Minute)),
+		clone("bob", "five", ago(10*time.Minute)),
+	}
+	c, _ := auditServer(t, entries, true)
+
+	for _, tc := range []struct {
+		density float64
+		want    []string
+	}{
+		// Counting repos by default
+		{0, []string{
+			"bob git.clone acme/one", "bob git.clone acme/two", "bob git.clone acme/three", "bob git.clone acme/four",
+			"alice git.clone acme/one", "alice git.clone acme/two", "alice git.clone acme/three", "bob git.clone acme/five",
+		}},
+		{1, []string{"alice git.clone acme/one", "alice git.clone acme/two", "alice git.clone acme/three"}},
+	} {
+		s := Settings{
+			Org:             testOrg,
+			Since:           ago(time.Hour),
+			MaxClonesSince:  ago(time.Hour),
+			ClonesInclude:   "git",
+			MaxClonedRepos:  3,
+			MinCloneDensity: tc.density,
+		}
+		got, err := cloneEvents(context.Background(), c, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalStrings(actions(got), tc.want) {
+			t.Errorf("MinCloneDensity %v: cloneEvents = %q, want %q", tc.density, actions(got), tc.want)
+		}
+	}
+}
+
+func TestCloneEventsCritical(t *testing.T) {
+	critical, err := normalizeRepos(testOrg, []string{"vault", "*-prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []*github.AuditEntry{
+		// Three critical repos, below the global threshold
+		clone("alice", "vault", ago(50*time.Minute)),
+		clone("alice", "api-prod", ago(40*time.Minute)),
+		clone("alice", "web-prod", ago(30*time.Minute)),
+		clone("alice", "docs", ago(20*time.Minute)),
+		// Cloning one critical repo repeatedly counts once
+		clone("bob", "vault", ago(50*time.Minute)),
+		clone("bob", "vault", ago(40*time.Minute)),
+		clone("bob", "vault", ago(30*time.Minute)),
+		clone("bob", "docs", ago(20*time.Minute)),
+		// Two critical repos among many others
+		clone("carol", "vault", ago(50*time.Minute)),
+		clone("carol", "api-prod", ago(45*time.Minute)),
+		clone("carol", "one", ago(40*time.Minute)),
+		clone("carol", "two", ago(35*time.Minute)),
+		clone("carol", "three", ago(30*time.Minute)),
+		clone("carol", "four", ago(25*time.Minute)),
+	}
+	c, _ := auditServer(t, entries, true)
+
+	for _, tc := range []struct {
+		name         string
+		maxRepos     int
+		maxCritical  int
+		wantAlerters []string
+	}{
+		{"critical threshold off", 5, 0, []string{"carol"}},
+		{"critical threshold", 5, 3, []string{"alice", "carol"}},
+		{"critical threshold of one", 10, 1, []string{"alice", "bob", "carol"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := Settings{
+				Org:                    testOrg,
+				Since:                  ago(time.Hour),
+				MaxClonesSince:         ago(time.Hour),
+				ClonesInclude:          "git",
+				MaxClonedRepos:         tc.maxRepos,
+				MaxCriticalClonedRepos: tc.maxCritical,
+				CriticalRepos:          critical,
+			}
+			got, err := cloneEvents(context.Background(), c, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if alerters := actors(got); !equalStrings(alerters, tc.wantAlerters) {
+				t.Errorf("alerted on %q, want %q", alerters, tc.wantAlerters)
+			}
+		})
+	}
+}
+
+func TestCloneEventsMinDistinct(t *testing.T) {
+	fork := func(actor string, repo string, at time.Time) *github.AuditEntry {
+		a := clone(actor, repo, at)
+		a.Repo = github.String("alice-forks/" + repo)
+		a.Repository = github.String("alice-forks/" + repo)
+		return a
+	}
+	entries := []*github.AuditEntry{
+		clone("alice", "one", ago(40*time.Minute)),
+		clone("alice", "two", ago(30*time.Minute)),
+		clone("alice", "three", ago(20*time.Minute)),
+		// Cloning a repo and its fork counts as one base name, but two repositories
+		clone("bob", "one", ago(40*time.Minute)),
+		fork("bob", "one", ago(35*time.Minute)),
+		clone("bob", "two", ago(30*time.Minute)),
+		clone("bob", "three", ago(20*time.Minute)),
+		// Forks alone do not reach the base name threshold
+		clone("carol", "one", ago(40*time.Minute)),
+		fork("carol", "one", ago(30*time.Minute)),
+		clone("carol", "two", ago(20*time.Minute)),
+		fork("carol", "two", ago(10*time.Minute)),
+	}
+	c, _ := auditServer(t, entries, true)
+
+	for _, tc := range []struct {
+		minDistinct  int
+		wantAlerters []string
+	}{
+		{0, []string{"alice", "bob"}},
+		{3, []string{"alice", "bob"}},
+		{4, []string{"bob"}},
+		{5, []string{}},
+	} {
+		s := Settings{
+			Org:                    testOrg,
+			Since:                  ago(time.Hour),
+			MaxClonesSince:         ago(time.Hour),
+			ClonesInclude:          "git",
+			MaxClonedRepos:         3,
+			MinDistinctClonedRepos: tc.minDistinct,
+		}
+		got, err := cloneEvents(context.Background(), c, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if alerters := actors(got); !equalStrings(alerters, tc.wantAlerters) {
+			t.Errorf("MinDistinctClonedRepos %d: alerted on %q, want %q", tc.minDistinct, alerters, tc.wantAlerters)
+		}
+	}
+}
+
+func TestCloneEventsDedupeBursts(t *testing.T) {
+	burst := []*github.AuditEntry{
+		clone("alice", "one", ago(170*time.Minute)),
+		clone("alice", "two", ago(160*time.Minute)),
+		clone("alice", "three", ago(150*time.Minute)),
+	}
+	grown := append(slices.Clone(burst), clone("alice", "four", ago(120*time.Minute)))
+	later := []*github.AuditEntry{
+		clone("alice", "five", ago(50*time.Minute)),
+		clone("alice", "six", ago(40*time.Minute)),
+		clone("alice", "seven", ago(30*time.Minute)),
+	}
+
+	cb, err := loadCloneBursts(filepath.Join(t.TempDir(), "clone-bursts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The runs are in order, sharing the recorded bursts
+	for _, tc := range []struct {
+		name    string
+		entries []*github.AuditEntry
+		since   time.Duration
+		window  time.Duration
+		want    []string
+	}{
+		{"first alert", burst, 3 * time.Hour, 6 * time.Hour, []string{"alice"}},
+		{"same burst seen by the next run", burst, 3 * time.Hour, 6 * time.Hour, []string{}},
+		{"burst grows", grown, 3 * time.Hour, 6 * time.Hour, []string{"alice"}},
+		{"grown burst seen again", grown, 3 * time.Hour, 6 * time.Hour, []string{}},
+		{"new burst after the window", append(slices.Clone(grown), later...), time.Hour, time.Hour, []string{"alice"}},
+		{"new burst seen again", later, time.Hour, time.Hour, []string{}},
+	} {
+		c, _ := auditServer(t, tc.entries, false)
+		s := Settings{
+			Org:            testOrg,
+			Since:          ago(tc.since),
+			MaxClonesSince: ago(tc.window),
+			ClonesInclude:  "git",
+			MaxClonedRepos: 3,
+			CloneBursts:    cb,
+		}
+		got, err := cloneEvents(context.Background(), c, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if alerters := actors(got); !equalStrings(alerters, tc.want) {
+			t.Errorf("%s: alerted on %q, want %q", tc.name, alerters, tc.want)
+		}
+		if err := cb.save(s.MaxClonesSince); err != nil {
+			t.Fatal(err)
+		}
+		if cb, err = loadCloneBursts(cb.path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Bursts that started before the window are forgotten
+	if len(cb.Bursts) != 1 || !cb.Bursts[0].Start.Equal(ago(50*time.Minute)) {
+		t.Errorf("bursts = %+v, want only the new burst", cb.Bursts)
+	}
+}
+
+func TestIncludeBots(t *testing.T) {
+	entries := []*github.AuditEntry{
+		entry("repo.destroy", "deployer[bot]", "widgets", ago(40*time.Minute)),
+		entry("repo.destroy", "alice", "gadgets", ago(30*time.Minute)),
+	}
+	for _, r := range []string{"one", "two", "three"} {
+		entries = append(entries, clone("deployer[bot]", r, ago(20*time.Minute)))
+	}
+	c, _ := auditServer(t, entries, true)
+
+	for _, tc := range []struct {
+		include bool
+		web     []string
+		clones  []string
+	}{
+		{false, []string{"alice"}, []string{}},
+		{true, []string{"alice", "deployer[bot]"}, []string{"deployer[bot]"}},
+	} {
+		s := Settings{
+			Org:                      testOrg,
+			Since:                    ago(time.Hour),
+			MaxClonesSince:           ago(time.Hour),
+			ClonesInclude:            "git",
+			MaxClonedRepos:           3,
+			BotNames:                 []string{"[bot]"},
+			IncludeBots:              tc.include,
+			GlobalIgnoreActions:      []string{"org.update_member"},
+			NonCriticalIgnoreActions: []string{"repo.add_topic"},
+		}
+		web := webEvents(entries, s)
+		if got := actors(web); !equalStrings(got, tc.web) {
+			t.Errorf("IncludeBots %v: webEvents by %q, want %q", tc.include, got, tc.web)
+		}
+		clones, err := cloneEvents(context.Background(), c, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := actors(clones); !equalStrings(got, tc.clones) {
+			t.Errorf("IncludeBots %v: cloneEvents by %q, want %q", tc.include, got, tc.clones)
+		}
+
+		for _, e := range append(web, clones...) {
+			bot := e.GetActor() == "deployer[bot]"
+			if got := strings.HasPrefix(auditMsg(e, s), "bot: "); got != bot {
+				t.Errorf("auditMsg(%s) = %q, want bot prefix %v", e.GetActor(), auditMsg(e, s), bot)
+			}
+		}
+	}
+}
+
+func TestSplitRepoIDs(t *testing.T) {
+	ids, names, err := splitRepoIDs([]string{"widgets", " id:42", "id:7", "other/gadgets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[42 7]" || !equalStrings(names, []string{"widgets", "other/gadgets"}) {
+		t.Errorf("splitRepoIDs = %v, %q", ids, names)
+	}
+	for _, r := range []string{"id:", "id:abc", "id:-1", "id:0"} {
+		if _, _, err := splitRepoIDs([]string{r}); err == nil {
+			t.Errorf("splitRepoIDs accepted %q", r)
+		}
+	}
+}
+
+func TestCriticalRepoIDs(t *testing.T) {
+	mux := http.NewServeMux()
+	// The repository was renamed since it was listed as critical
+	mux.HandleFunc("/repositories/42", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"id": 42, "full_name": "acme/Vault-Renamed"}`)
+	})
+	mux.HandleFunc("/repositories/7", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := github.NewClient(nil)
+	c.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	ids, names, err := splitRepoIDs([]string{"id:42", "id:7", "widgets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, failed, err := repoNamesByID(context.Background(), c, ids)
+	if err == nil || !strings.Contains(err.Error(), "id:7") {
+		t.Errorf("error = %v, want one for id:7", err)
+	}
+	if fmt.Sprint(failed) != "[7]" {
+		t.Errorf("failed = %v, want [7]", failed)
+	}
+
+	critical, err := normalizeRepos(testOrg, append(names, found...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for repo, want := range map[string]bool{
+		"acme/vault-renamed": true,
+		"acme/widgets":       true,
+		"acme/vault":         false,
+	} {
+		if got := critical.has(repo); got != want {
+			t.Errorf("critical %q = %v, want %v", repo, got, want)
+		}
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	id := correlationID(entry("repo.destroy", "alice", "widgets", start.Add(time.Minute)), time.Hour)
+	if got := correlationID(entry("org.remove_member", "alice", "", start.Add(59*time.Minute)), time.Hour); got != id {
+		t.Errorf("same actor and hour: correlationID = %s, want %s", got, id)
+	}
+	if got := correlationID(entry("repo.destroy", "alice", "widgets", start.Add(61*time.Minute)), time.Hour); got == id {
+		t.Errorf("next hour: correlationID = %s, want a new ID", got)
+	}
+	if got := correlationID(entry("repo.destroy", "bob", "widgets", start.Add(time.Minute)), time.Hour); got == id {
+		t.Errorf("another actor: correlationID = %s, want a new ID", got)
+	}
+
+	// Deleted users are correlated under the name their alerts show
+	deleted := entry("repo.destroy", "", "widgets", start.Add(time.Minute))
+	deleted.Actor = nil
+	if got, want := correlationID(deleted, time.Hour), correlationID(entry("repo.destroy", deletedActor, "widgets", start), time.Hour); got != want {
+		t.Errorf("deleted user: correlationID = %s, want %s", got, want)
+	}
+}
+
+func TestSlackNotifiers(t *testing.T) {
+	threader := &slackThreader{}
+	webhooks := []string{"https://hooks.slack.com/one", "https://hooks.slack.com/two"}
+	for _, tc := range []struct {
+		name         string
+		webhooks     []string
+		threader     *slackThreader
+		withWebhooks bool
+		want         []notifier
+	}{
+		{"webhooks", webhooks, nil, false, []notifier{slackNotifier{url: webhooks[0]}, slackNotifier{url: webhooks[1]}}},
+		{"logging fallback", nil, nil, false, []notifier{slackNotifier{}}},
+		{"threader replaces webhooks", webhooks, threader, false, []notifier{threader}},
+		{"threader replaces the fallback", nil, threader, true, []notifier{threader}},
+		{"threader with webhooks", webhooks, threader, true, []notifier{threader, slackNotifier{url: webhooks[0]}, slackNotifier{url: webhooks[1]}}},
+	} {
+		if got := slackNotifiers(tc.webhooks, tc.threader, tc.withWebhooks); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: slackNotifiers = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// offenderState tracks an actor who was alerted on in consecutive runs
+type offenderState struct {
+	Runs int       `json:"runs"`
+	Last time.Time `json:"last"`
+}
+
+// offenders escalates alerts for actors who are alerted on run after run.
+// An actor with no alerts in a run starts over.
+type offenders struct {
+	path string
+	// steps are the consecutive runs after which alerts are raised one more severity level
+	steps []int
+
+	Actors map[string]*offenderState `json:"actors"`
+	// flagged are the actors alerted on in this run
+	flagged map[string]bool
+}
+
+// parseSteps parses comma separated, increasing run counts
+func parseSteps(list string) ([]int, error) {
+	steps := []int{}
+	for _, f := range strings.Split(list, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 || (len(steps) > 0 && n <= steps[len(steps)-1]) {
+			return nil, fmt.Errorf("run counts must be positive and increasing, got %d", n)
+		}
+		steps = append(steps, n)
+	}
+	return steps, nil
+}
+
+// loadOffenders reads the actors alerted on in previous runs from path
+func loadOffenders(path string, steps []int) (*offenders, error) {
+	o := &offenders{path: path, steps: steps, Actors: map[string]*offenderState{}, flagged: map[string]bool{}}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, o); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if o.Actors == nil {
+		o.Actors = map[string]*offenderState{}
+	}
+	return o, nil
+}
+
+// escalate counts this run against the alert's actor, raising its severity for each step reached
+func (o *offenders) escalate(al *alert) {
+	if o == nil || al.Entry == nil {
+		return
+	}
+
+	actor := actorName(al.Entry)
+	st := o.Actors[actor]
+	if st == nil {
+		st = &offenderState{}
+		o.Actors[actor] = st
+	}
+	if !o.flagged[actor] {
+		o.flagged[actor] = true
+		st.Runs++
+		st.Last = time.Now()
+	}
+
+	bump := 0
+	for _, n := range o.steps {
+		if st.Runs >= n {
+			bump++
+		}
+	}
+	if bump == 0 {
+		return
+	}
+
+	level := min(severityRank(al.Severity)+bump, len(severityLevels)-1)
+	if severityLevels[level] != al.Severity {
+		log.Printf("%s alerted on in %d consecutive runs, raising %s to %s", actor, st.Runs, al.Severity, severityLevels[level])
+		al.raise(severityLevels[level])
+	}
+}
+
+// save records the actors alerted on in this run, forgetting the rest
+func (o *offenders) save() error {
+	for actor := range o.Actors {
+		if !o.flagged[actor] {
+			delete(o.Actors, actor)
+		}
+	}
+
+	b, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(o.path, b)
+}
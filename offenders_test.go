@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOffendersEscalate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offenders.json")
+	steps := []int{2, 3}
+
+	// run loads the offenders, escalates an alert by each actor as one run would, and saves them
+	run := func(actors ...string) map[string]*alert {
+		o, err := loadOffenders(path, steps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]*alert{}
+		for _, actor := range actors {
+			al := newAlert(entry("repo.destroy", actor, "widgets", ago(time.Minute)), "", "destroyed widgets", repoSet{})
+			o.escalate(al)
+			out[actor] = al
+		}
+		if err := o.save(); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	if al := run("alice", "")[""]; al.Severity != severityMedium {
+		t.Errorf("first run: severity = %s, want medium", al.Severity)
+	}
+	got := run("alice", "")
+	if got["alice"].Severity != severityHigh || got["alice"].Mention {
+		t.Errorf("second run: severity = %s, mention %v; want high without mention", got["alice"].Severity, got["alice"].Mention)
+	}
+	// Deleted users are tracked together, like any other actor
+	if got[""].Severity != severityHigh {
+		t.Errorf("second run: deleted user severity = %s, want high", got[""].Severity)
+	}
+
+	al := run("alice")["alice"]
+	if al.Severity != severityCritical || !al.Mention {
+		t.Errorf("third run: severity = %s, mention %v; want critical with mention", al.Severity, al.Mention)
+	}
+	if !strings.HasPrefix(slackText(al), "<!channel> ") {
+		t.Errorf("third run: no mention: %s", slackText(al))
+	}
+
+	// A run without alerts for an actor starts them over
+	run("bob")
+	if al := run("alice")["alice"]; al.Severity != severityMedium {
+		t.Errorf("after a quiet run: severity = %s, want medium", al.Severity)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// opsgenieClient creates Opsgenie alerts, failing a delivery rather than hanging the run if Opsgenie is unresponsive
+var opsgenieClient = &http.Client{Timeout: 30 * time.Second}
+
+// opsgeniePriority maps alert severities to Opsgenie priorities
+var opsgeniePriority = map[string]string{
+	severityCritical: "P1",
+	severityHigh:     "P2",
+	severityMedium:   "P3",
+}
+
+// opsgenieAlert is the request body for creating an Opsgenie alert
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// opsgenieNotifier creates Opsgenie alerts, aliased by fingerprint so that Opsgenie deduplicates them
+type opsgenieNotifier struct {
+	apiURL string
+	apiKey string
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+func (n *opsgenieNotifier) Notify(ctx context.Context, al *alert) error {
+	a := al.Entry
+	og := opsgenieAlert{
+		Message:     truncate(alertTitle(a, al.String()), 130),
+		Alias:       al.fingerprint(),
+		Description: truncate(al.String(), 15000),
+		Priority:    opsgeniePriority[al.Severity],
+		Tags:        []string{"github-audit-alerter"},
+	}
+	if a != nil {
+		og.Tags = append(og.Tags, a.GetAction())
+		og.Details = map[string]string{
+			"actor":    actorName(a),
+			"action":   a.GetAction(),
+			"location": auditLocation(a),
+		}
+	}
+	for _, d := range al.Details {
+		if og.Details == nil {
+			og.Details = map[string]string{}
+		}
+		og.Details[d.Name] = d.Value
+	}
+
+	b, err := json.Marshal(og)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(n.apiURL, "/")+"/v2/alerts", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+n.apiKey)
+
+	log.Printf("[opsgenie post] %s", og.Message)
+	resp, err := opsgenieClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("opsgenie: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("opsgenie: %s: %s", resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// permissionCheck is a minimal API call standing in for what some detectors or sinks need
+type permissionCheck struct {
+	what string
+	// usedBy lists the enabled features that need the call to succeed
+	usedBy string
+	call   func(ctx context.Context) (*github.Response, error)
+}
+
+// permissionChecks returns a check for each kind of API access the current flags need
+func permissionChecks(c *github.Client, s Settings) []permissionCheck {
+	auditLog := func(kind string) func(ctx context.Context) (*github.Response, error) {
+		return func(ctx context.Context) (*github.Response, error) {
+			opts := &github.GetAuditLogOptions{Include: github.String(kind)}
+			opts.ListCursorOptions.PerPage = 1
+			_, resp, err := c.Organizations.GetAuditLog(ctx, s.Org, opts)
+			return resp, err
+		}
+	}
+
+	checks := []permissionCheck{}
+	if *phraseFlag != "" {
+		return append(checks, permissionCheck{phraseInclude + " audit log", "--phrase", auditLog(phraseInclude)})
+	}
+	checks = append(checks,
+		permissionCheck{"web audit log", "web events, bursts, and token and visibility correlation", auditLog("web")},
+		permissionCheck{s.ClonesInclude + " audit log", "excessive clones", auditLog(s.ClonesInclude)},
+	)
+	if s.EventsInclude != "web" {
+		checks = append(checks, permissionCheck{s.EventsInclude + " audit log", "--audit-include=events=" + s.EventsInclude, auditLog(s.EventsInclude)})
+	}
+
+	if (*checkCriticalFlag && len(s.CriticalRepos.names) > 0) || *criticalTopicFlag != "" {
+		checks = append(checks, permissionCheck{"repository list", "--check-critical-repos and --critical-topic", func(ctx context.Context) (*github.Response, error) {
+			opts := &github.RepositoryListByOrgOptions{}
+			opts.ListOptions.PerPage = 1
+			_, resp, err := c.Repositories.ListByOrg(ctx, s.Org, opts)
+			return resp, err
+		}})
+	}
+
+	if owner, repo, ok := strings.Cut(*issueRepoFlag, "/"); ok {
+		checks = append(checks, permissionCheck{"issue repository", "--issue-repo", func(ctx context.Context) (*github.Response, error) {
+			r, resp, err := c.Repositories.Get(ctx, owner, repo)
+			if err == nil && !r.GetPermissions()["triage"] && !r.GetPermissions()["push"] {
+				err = fmt.Errorf("the token can read %s but not open or close issues in it", *issueRepoFlag)
+			}
+			return resp, err
+		}})
+	}
+	return checks
+}
+
+// permissionHint explains a failed check in terms of what to change, if it is a common failure
+func permissionHint(err error) string {
+	var rle *github.RateLimitError
+	var arle *github.AbuseRateLimitError
+	var er *github.ErrorResponse
+	switch {
+	case errors.As(err, &rle), errors.As(err, &arle):
+		return "rate limited, try again later"
+	case errors.As(err, &er) && er.Response != nil:
+		switch er.Response.StatusCode {
+		case http.StatusUnauthorized:
+			return "GITHUB_TOKEN is invalid or expired"
+		case http.StatusForbidden:
+			return "the token lacks access: classic tokens need the read:audit_log scope (and repo for private repositories), " +
+				"fine-grained tokens and apps need the organization's \"Administration\" read permission, and the token's owner must be an organization owner"
+		case http.StatusNotFound:
+			return "not found: check --org and --issue-repo, and that the organization is on GitHub Enterprise Cloud, which the audit log API requires"
+		}
+	}
+	return ""
+}
+
+// checkPermissions runs permissionChecks, printing the outcome of each, and reports whether all passed
+func checkPermissions(ctx context.Context, c *github.Client, s Settings) bool {
+	ok := true
+	scopes := ""
+	for _, pc := range permissionChecks(c, s) {
+		resp, err := pc.call(ctx)
+		if resp != nil && resp.Header.Get("X-OAuth-Scopes") != "" {
+			scopes = resp.Header.Get("X-OAuth-Scopes")
+		}
+		if err != nil {
+			ok = false
+			fmt.Printf("FAIL %s, needed for %s: %v\n", pc.what, pc.usedBy, err)
+			if hint := permissionHint(err); hint != "" {
+				fmt.Printf("     %s\n", hint)
+			}
+			continue
+		}
+		fmt.Printf("ok   %s, needed for %s\n", pc.what, pc.usedBy)
+	}
+	if scopes != "" {
+		fmt.Printf("token scopes: %s\n", scopes)
+	}
+	return ok
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v53/github"
+)
+
+func TestPermissionChecksPhrase(t *testing.T) {
+	includes := []string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/"+testOrg+"/audit-log", func(w http.ResponseWriter, r *http.Request) {
+		includes = append(includes, r.URL.Query().Get("include"))
+		fmt.Fprint(w, "[]")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	c := github.NewClient(nil)
+	c.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	defer func(phrase string) { *phraseFlag = phrase }(*phraseFlag)
+	*phraseFlag = "action:repo.destroy"
+	checks := permissionChecks(c, Settings{Org: testOrg})
+	if len(checks) != 1 {
+		t.Fatalf("%d checks, want 1 for --phrase", len(checks))
+	}
+	if _, err := checks[0].call(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The check queries the audit log the same way the search does
+	if !equalStrings(includes, []string{phraseInclude}) {
+		t.Errorf("includes = %q, want %q", includes, phraseInclude)
+	}
+}
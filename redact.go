@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// redacted replaces text matching a --redact pattern
+const redacted = "***"
+
+// compileRedactions compiles the --redact patterns
+func compileRedactions(patterns []string) ([]*regexp.Regexp, error) {
+	res := []*regexp.Regexp{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// redact replaces every match of the patterns in text
+func redact(text string, res []*regexp.Regexp) string {
+	for _, re := range res {
+		text = re.ReplaceAllLiteralString(text, redacted)
+	}
+	return text
+}
+
+// redactEntry returns a copy of an entry with the patterns redacted from its string fields. Timestamps are
+// left intact, so that the copy always parses; if it still cannot be redacted, only its action and time are kept.
+func redactEntry(a *github.AuditEntry, res []*regexp.Regexp) *github.AuditEntry {
+	if a == nil || len(res) == 0 {
+		return a
+	}
+
+	out := &github.AuditEntry{}
+	b, err := json.Marshal(a)
+	if err == nil {
+		var v any
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err = d.Decode(&v); err == nil {
+			if b, err = json.Marshal(redactValue("", v, res)); err == nil {
+				err = json.Unmarshal(b, out)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("unable to redact entry, keeping only its action and time: %v", err)
+		return &github.AuditEntry{Action: a.Action, Timestamp: a.Timestamp}
+	}
+	return out
+}
+
+// redactValue redacts the strings within a decoded JSON value, except those of timestamp fields such as
+// @timestamp and created_at, named by key
+func redactValue(key string, v any, res []*regexp.Regexp) any {
+	switch v := v.(type) {
+	case string:
+		if key == "@timestamp" || strings.HasSuffix(key, "_at") {
+			return v
+		}
+		return redact(v, res)
+	case []any:
+		for i := range v {
+			v[i] = redactValue("", v[i], res)
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = redactValue(k, v[k], res)
+		}
+	}
+	return v
+}
+
+// redactor wraps a notifier, redacting alert text and entries before delivery
+type redactor struct {
+	n   notifier
+	res []*regexp.Regexp
+}
+
+func (r redactor) Notify(ctx context.Context, al *alert) error {
+	details := []alertDetail{}
+	for _, d := range al.Details {
+		details = append(details, alertDetail{Name: d.Name, Value: redact(d.Value, r.res)})
+	}
+	// Copied so that fields added to alerts are passed on, with only those that may hold sensitive text replaced
+	c := *al
+	c.Entry = redactEntry(al.Entry, r.res)
+	c.Kind = redact(al.Kind, r.res)
+	c.Message = redact(al.Message, r.res)
+	c.Details = details
+	// Fingerprinted before redaction, so that deduplication does not depend on the patterns
+	c.Fingerprint = al.fingerprint()
+	return r.n.Notify(ctx, &c)
+}
+
+func (r redactor) Flush(ctx context.Context) error {
+	if f, ok := r.n.(flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v53/github"
+)
+
+func TestRedact(t *testing.T) {
+	for _, tc := range []struct {
+		patterns []string
+		in       string
+		want     string
+	}{
+		{[]string{`ghp_[A-Za-z0-9]+`}, "token ghp_abc123 leaked", "token *** leaked"},
+		{[]string{`secret-\w+`, `key-\d+`}, "secret-db and key-42", "*** and ***"},
+		{[]string{`nomatch`}, "unchanged", "unchanged"},
+		{nil, "unchanged", "unchanged"},
+	} {
+		res, err := compileRedactions(tc.patterns)
+		if err != nil {
+			t.Fatalf("compileRedactions(%q): %v", tc.patterns, err)
+		}
+		if got := redact(tc.in, res); got != tc.want {
+			t.Errorf("redact(%q, %q) = %q, want %q", tc.in, tc.patterns, got, tc.want)
+		}
+	}
+
+	if _, err := compileRedactions([]string{"("}); err == nil {
+		t.Error("compileRedactions accepted an invalid regexp")
+	}
+}
+
+func TestRedactEntry(t *testing.T) {
+	a := entry("repo.update_actions_secret", "alice", "secret-widgets", ago(0))
+	a.Name = github.String("secret-token")
+	a.RepositoryPublic = github.Bool(true)
+
+	// Matching digits would break the timestamp and document ID if the entry were redacted as JSON text
+	res := []*regexp.Regexp{regexp.MustCompile(`secret-\w+`), regexp.MustCompile(`[0-9]+`)}
+	got := redactEntry(a, res)
+	if got == nil {
+		t.Fatal("redactEntry returned nil")
+	}
+	if got.GetName() != "***" || got.GetRepo() != "acme/***" {
+		t.Errorf("name %q and repo %q were not redacted", got.GetName(), got.GetRepo())
+	}
+	if !got.GetTimestamp().Equal(a.GetTimestamp()) {
+		t.Errorf("timestamp = %s, want %s", got.GetTimestamp(), a.GetTimestamp())
+	}
+	if !got.GetRepositoryPublic() {
+		t.Error("repository_public was lost")
+	}
+	if a.GetName() != "secret-token" {
+		t.Errorf("the original entry was changed: %q", a.GetName())
+	}
+
+	if redactEntry(nil, res) != nil {
+		t.Error("redactEntry(nil) is not nil")
+	}
+	if redactEntry(a, nil) != a {
+		t.Error("redactEntry without patterns did not return the entry")
+	}
+}
+
+func TestRedactorNotify(t *testing.T) {
+	a := entry("org.disable_two_factor_requirement", "alice", "", ago(0))
+	al := newAlert(a, "", "alice disabled 2FA with secret-reason", repoSet{})
+	al.Trace = &alertTrace{Detector: "web"}
+	al.addDetail("note", "secret-detail")
+	al.Category = "access"
+
+	n := &fakeNotifier{}
+	r := redactor{n: n, res: []*regexp.Regexp{regexp.MustCompile(`secret-\w+`), regexp.MustCompile(`[0-9]+`)}}
+	if err := r.Notify(context.Background(), al); err != nil {
+		t.Fatal(err)
+	}
+	got := n.sent[0]
+
+	if strings.Contains(got.String(), "secret-") {
+		t.Errorf("alert was not redacted: %s", got)
+	}
+	if got.fingerprint() != al.fingerprint() {
+		t.Errorf("fingerprint = %q, want %q from before redaction", got.fingerprint(), al.fingerprint())
+	}
+	if got.Category != "access" || got.Severity != al.Severity {
+		t.Errorf("category, severity = %q, %q, want %q, %q", got.Category, got.Severity, "access", al.Severity)
+	}
+	if got.Trace != al.Trace {
+		t.Error("trace was dropped")
+	}
+	if !strings.HasPrefix(slackText(got), "<!channel> ") {
+		t.Errorf("always-alert mention was lost: %s", slackText(got))
+	}
+	if al.Message != "alice disabled 2FA with secret-reason" {
+		t.Errorf("the original alert was changed: %q", al.Message)
+	}
+}
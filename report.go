@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// smtpTLSModes are the accepted values of --smtp-tls
+var smtpTLSModes = map[string]bool{"starttls": true, "tls": true, "none": true}
+
+// smtpConfig is how daily reports are emailed
+type smtpConfig struct {
+	addr string
+	from string
+	// tls is "starttls" to upgrade a plain connection, "tls" to connect with TLS, or "none"
+	tls      string
+	username string
+	password string
+}
+
+// dailyReport accumulates alerts across runs, so that they can be emailed as one summary once the day is over
+type dailyReport struct {
+	path string
+
+	// Start is when the first run contributing to the report began
+	Start time.Time `json:"start"`
+	// Counts tallies alerts by category, then actor
+	Counts map[string]alertCounts `json:"counts"`
+	// Critical counts the critical alerts among them
+	Critical int `json:"critical"`
+}
+
+// loadDailyReport reads the report accumulated so far from path, or starts one at now
+func loadDailyReport(path string, now time.Time) (*dailyReport, error) {
+	r := &dailyReport{path: path, Start: now, Counts: map[string]alertCounts{}}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if r.Counts == nil {
+		r.Counts = map[string]alertCounts{}
+	}
+	return r, nil
+}
+
+// due reports whether the report's day, in loc, is over by now
+func (r *dailyReport) due(now time.Time, loc *time.Location) bool {
+	y1, m1, d1 := r.Start.In(loc).Date()
+	y2, m2, d2 := now.In(loc).Date()
+	return y1 != y2 || m1 != m2 || d1 != d2
+}
+
+// reset starts a new report at now
+func (r *dailyReport) reset(now time.Time) {
+	r.Start = now
+	r.Counts = map[string]alertCounts{}
+	r.Critical = 0
+}
+
+// add counts an alert about an entry towards the report
+func (r *dailyReport) add(al *alert) {
+	if r == nil || al.Entry == nil {
+		return
+	}
+	category := alertCategory(al)
+	if r.Counts[category] == nil {
+		r.Counts[category] = alertCounts{}
+	}
+	r.Counts[category][actorName(al.Entry)]++
+	if al.Severity == severityCritical {
+		r.Critical++
+	}
+}
+
+func (r *dailyReport) total() int {
+	n := 0
+	for _, c := range r.Counts {
+		n += c.total()
+	}
+	return n
+}
+
+func (r *dailyReport) save() error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.path, b)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<html><body>
+<h2>{{.Total}} GitHub audit alerts for {{.Org}}</h2>
+<p>From {{.Start}} to {{.End}}{{if .Critical}}, including <b>{{.Critical}} critical</b>{{end}}.</p>
+{{range .Categories}}<h3>{{.Name}}: {{.Total}}</h3>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th align="left">Actor</th><th align="right">Alerts</th></tr>
+{{range .Actors}}<tr><td>{{.Name}}</td><td align="right">{{.Count}}</td></tr>
+{{end}}</table>
+{{else}}<p>No alerts.</p>
+{{end}}</body></html>
+`))
+
+type reportRow struct {
+	Name  string
+	Count int
+}
+
+type reportCategory struct {
+	Name   string
+	Total  int
+	Actors []reportRow
+}
+
+// html renders the report, with categories and then actors ordered by their number of alerts
+func (r *dailyReport) html(org string, end time.Time) (string, error) {
+	categories := []reportCategory{}
+	for name, actors := range r.Counts {
+		rc := reportCategory{Name: name, Total: actors.total()}
+		for actor, n := range actors {
+			rc.Actors = append(rc.Actors, reportRow{Name: actor, Count: n})
+		}
+		sort.Slice(rc.Actors, func(i, j int) bool {
+			if rc.Actors[i].Count != rc.Actors[j].Count {
+				return rc.Actors[i].Count > rc.Actors[j].Count
+			}
+			return rc.Actors[i].Name < rc.Actors[j].Name
+		})
+		categories = append(categories, rc)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Total != categories[j].Total {
+			return categories[i].Total > categories[j].Total
+		}
+		return categories[i].Name < categories[j].Name
+	})
+
+	var b bytes.Buffer
+	err := reportTemplate.Execute(&b, map[string]any{
+		"Org":        org,
+		"Total":      r.total(),
+		"Critical":   r.Critical,
+		"Start":      formatTime(r.Start),
+		"End":        formatTime(end),
+		"Categories": categories,
+	})
+	return b.String(), err
+}
+
+// emailReport emails the report to --daily-report-to, as ending at end
+func emailReport(r *dailyReport, org string, end time.Time) error {
+	body, err := r.html(org, end)
+	if err != nil {
+		return err
+	}
+	cfg := smtpConfig{
+		addr:     *smtpAddrFlag,
+		from:     *smtpFromFlag,
+		tls:      *smtpTLSFlag,
+		username: *smtpUsernameFlag,
+		password: os.Getenv("GH_AUDIT_SMTP_PASSWORD"),
+	}
+	to := []string{}
+	for _, addr := range strings.Split(*dailyReportToFlag, ",") {
+		to = append(to, strings.TrimSpace(addr))
+	}
+	subject := fmt.Sprintf("GitHub audit report for %s: %d alerts", org, r.total())
+	log.Printf("emailing daily report of %d alerts since %s to %s", r.total(), r.Start.Format(time.RFC3339), strings.Join(to, ", "))
+	return sendMail(cfg, to, subject, body)
+}
+
+// sendMail emails an HTML body to each of to
+func sendMail(cfg smtpConfig, to []string, subject string, body string) error {
+	host, _, err := net.SplitHostPort(cfg.addr)
+	if err != nil {
+		return fmt.Errorf("smtp addr: %w", err)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	if cfg.tls == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.addr)
+	}
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if cfg.tls == "starttls" {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if cfg.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.username, cfg.password, host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(cfg.from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("%s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	headers := []string{
+		"From: " + cfg.from,
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/html; charset=UTF-8",
+	}
+	if _, err := fmt.Fprintf(w, "%s\r\n\r\n%s", strings.Join(headers, "\r\n"), body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
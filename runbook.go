@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v53/github"
+)
+
+// runbook links alerts for matching actions to a response guide
+type runbook struct {
+	re *regexp.Regexp
+	// url may contain {org}, {repo}, and {action}, which are replaced with the entry's. {repo} is the repo's
+	// name without its org, so that "org/repo" can be written as "{org}/{repo}".
+	url string
+}
+
+// parseRunbooks parses "action-regexp=url" pairs, matching actions in full and ignoring case like the
+// ignore lists, followed by a default URL for any action if one is given
+func parseRunbooks(pairs []string, defaultURL string) ([]runbook, error) {
+	rs := []runbook{}
+	for _, p := range pairs {
+		pattern, u, ok := strings.Cut(p, "=")
+		if !ok || pattern == "" || u == "" {
+			return nil, fmt.Errorf("%q is not in action-regexp=url form", p)
+		}
+		re, err := regexp.Compile(fmt.Sprintf("(?i)^(?:%s)$", pattern))
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, runbook{re: re, url: u})
+	}
+	if defaultURL != "" {
+		rs = append(rs, runbook{re: regexp.MustCompile(".*"), url: defaultURL})
+	}
+	return rs, nil
+}
+
+// runbookURL returns the URL of the first runbook matching an entry's action, if any
+func runbookURL(a *github.AuditEntry, rs []runbook) string {
+	// Git events, such as git.clone, only have the repository field
+	repo := a.GetRepo()
+	if repo == "" {
+		repo = a.GetRepository()
+	}
+	if repo != "" {
+		repo = path.Base(repo)
+	}
+	for _, r := range rs {
+		if !r.re.MatchString(a.GetAction()) {
+			continue
+		}
+		return strings.NewReplacer(
+			"{org}", url.PathEscape(a.GetOrg()),
+			"{repo}", url.PathEscape(repo),
+			"{action}", url.PathEscape(a.GetAction()),
+		).Replace(r.url)
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v53/github"
+)
+
+func TestRunbookURL(t *testing.T) {
+	rs, err := parseRunbooks([]string{
+		"git\\.clone=https://wiki.example.com/clones/{org}/{repo}",
+		"repo\\..*=https://wiki.example.com/repos/{repo}?action={action}",
+	}, "https://wiki.example.com/default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Git events only carry the repository field
+	gitClone := &github.AuditEntry{
+		Action:     github.String("git.clone"),
+		Actor:      github.String("alice"),
+		Org:        github.String(testOrg),
+		Repository: github.String(testOrg + "/widgets"),
+		Timestamp:  &github.Timestamp{Time: ago(time.Minute)},
+	}
+
+	for _, tc := range []struct {
+		name string
+		a    *github.AuditEntry
+		want string
+	}{
+		{"clone", gitClone, "https://wiki.example.com/clones/acme/widgets"},
+		{"web event", entry("Repo.Destroy", "alice", "widgets", ago(time.Minute)), "https://wiki.example.com/repos/widgets?action=Repo.Destroy"},
+		{"default", entry("org.add_member", "alice", "", ago(time.Minute)), "https://wiki.example.com/default"},
+	} {
+		if got := runbookURL(tc.a, rs); got != tc.want {
+			t.Errorf("%s: runbookURL = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	if got := runbookURL(gitClone, nil); got != "" {
+		t.Errorf("runbookURL without runbooks = %q, want none", got)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// sampler rate-limits a high-volume log line, reporting how many lines it suppressed
+type sampler struct {
+	// perSecond is the most lines logged each second; zero logs every line
+	perSecond int
+
+	second     time.Time
+	count      int
+	suppressed int
+}
+
+// foundLog samples the per-event "found" log lines, see --found-log-rate
+var foundLog = &sampler{}
+
+func (s *sampler) Printf(format string, args ...any) {
+	now := time.Now().Truncate(time.Second)
+	if !now.Equal(s.second) {
+		s.Flush()
+		s.second = now
+		s.count = 0
+	}
+
+	if s.perSecond > 0 && s.count >= s.perSecond {
+		s.suppressed++
+		return
+	}
+	s.count++
+	log.Printf(format, args...)
+}
+
+// Flush reports any lines suppressed so far
+func (s *sampler) Flush() {
+	if s.suppressed > 0 {
+		log.Printf("(+%d more suppressed)", s.suppressed)
+		s.suppressed = 0
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// sarifLevels maps alert severities to SARIF result levels
+var sarifLevels = map[string]string{
+	severityMedium:   "note",
+	severityHigh:     "warning",
+	severityCritical: "error",
+}
+
+// The subset of SARIF 2.1.0 needed for code scanning uploads
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Organization   string      `json:"organization,omitempty"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	Properties          map[string]any    `json:"properties,omitempty"`
+}
+
+// sarifLocation names the repo or org an entry applies to. Audit entries are not about files, so it is a
+// logical location rather than an artifact.
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifNotifier collects the run's alerts, writing them as one SARIF log when flushed.
+// Each action is a rule, and alerts without an entry share the "alerter" rule.
+type sarifNotifier struct {
+	w       io.Writer
+	vendor  string
+	product string
+	version string
+
+	results []sarifResult
+	rules   map[string]string
+}
+
+func (*sarifNotifier) local() {}
+
+func (n *sarifNotifier) Notify(_ context.Context, al *alert) error {
+	r := sarifResult{
+		RuleID:  "alerter",
+		Level:   sarifLevels[al.Severity],
+		Message: sarifMessage{Text: al.String()},
+	}
+	desc := "Alerter notifications"
+	if a := al.Entry; a != nil {
+		r.RuleID = a.GetAction()
+		desc = a.GetAction() + " audit events"
+		r.Locations = []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: auditLocation(a), Kind: "resource"}}}}
+		r.PartialFingerprints = map[string]string{"auditEntry/v1": al.fingerprint()}
+		r.Properties = map[string]any{"auditLogUrl": auditLogURL(a)}
+	}
+	if al.Trace != nil {
+		if r.Properties == nil {
+			r.Properties = map[string]any{}
+		}
+		r.Properties["trace"] = al.Trace
+	}
+
+	if n.rules == nil {
+		n.rules = map[string]string{}
+	}
+	n.rules[r.RuleID] = desc
+	n.results = append(n.results, r)
+	return nil
+}
+
+// Flush writes the SARIF log, even if there were no alerts, so that uploads always have a file
+func (n *sarifNotifier) Flush(_ context.Context) error {
+	driver := sarifDriver{
+		Name:           n.product,
+		Organization:   n.vendor,
+		Version:        n.version,
+		InformationURI: "https://github.com/chainguard-dev/github-audit-alerter",
+		Rules:          []sarifRule{},
+	}
+	ids := []string{}
+	for id := range n.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		driver.Rules = append(driver.Rules, sarifRule{ID: id, ShortDescription: sarifMessage{Text: n.rules[id]}})
+	}
+
+	results := n.results
+	if results == nil {
+		results = []sarifResult{}
+	}
+	e := json.NewEncoder(n.w)
+	e.SetIndent("", "  ")
+	return e.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSarifNotifier(t *testing.T) {
+	var b bytes.Buffer
+	n := &sarifNotifier{w: &b, product: "github-audit-alerter"}
+	a := entry("repo.destroy", "alice", "widgets", ago(time.Minute))
+	ctx := context.Background()
+	if err := n.Notify(ctx, newAlert(a, "", "alice destroyed widgets", repoSet{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(ctx, newAlert(nil, "", "alerter error", repoSet{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(b.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 2 || len(log.Runs[0].Tool.Driver.Rules) != 2 {
+		t.Fatalf("log = %s, want 2 results and rules", b.String())
+	}
+
+	// Audit entries are not files, so they have a logical location, with the audit log link as a property
+	r := results[0]
+	if r.RuleID != "repo.destroy" || len(r.Locations) != 1 || len(r.Locations[0].LogicalLocations) != 1 {
+		t.Fatalf("result = %+v, want one logical location", r)
+	}
+	if got := r.Locations[0].LogicalLocations[0].FullyQualifiedName; got != "acme/widgets" {
+		t.Errorf("location = %q, want acme/widgets", got)
+	}
+	if got := r.Properties["auditLogUrl"]; got != auditLogURL(a) {
+		t.Errorf("auditLogUrl = %v, want %s", got, auditLogURL(a))
+	}
+	if r := results[1]; r.RuleID != "alerter" || r.Locations != nil {
+		t.Errorf("alerter result = %+v, want the alerter rule without a location", r)
+	}
+}
+
+func TestSarifNotifierEmpty(t *testing.T) {
+	var b bytes.Buffer
+	if err := (&sarifNotifier{w: &b}).Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(b.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || log.Runs[0].Results == nil {
+		t.Errorf("log = %s, want a valid log without results", b.String())
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPrintSchema(t *testing.T) {
+	var b bytes.Buffer
+	if err := printSchema(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	var s struct {
+		Type       string   `json:"type"`
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Type       string         `json:"type"`
+			Format     string         `json:"format"`
+			Properties map[string]any `json:"properties"`
+			Items      map[string]any `json:"items"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &s); err != nil {
+		t.Fatalf("schema is not JSON: %v\n%s", err, b.String())
+	}
+	if s.Type != "object" {
+		t.Errorf("type = %q, want object", s.Type)
+	}
+	if !equalStrings(s.Required, []string{"message", "severity", "category"}) {
+		t.Errorf("required = %q, want the fields without omitempty", s.Required)
+	}
+
+	// Every field of an alert's JSON is described
+	al := newAlert(entry("repo.destroy", "alice", "widgets", ago(time.Minute)), "", "alice destroyed widgets", repoSet{})
+	al.addDetail("runbook", "https://example.com")
+	al.Trace = &alertTrace{Detector: "web"}
+	al.Mention = true
+	raw, err := json.Marshal(al)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for name := range fields {
+		if _, ok := s.Properties[name]; !ok {
+			t.Errorf("schema has no property %q", name)
+		}
+	}
+
+	if p := s.Properties["entry"]; p.Type != "object" || p.Properties["action"] == nil || p.Properties["actor_location"] == nil {
+		t.Errorf("entry = %+v, want the audit entry's fields", p)
+	}
+	if got := s.Properties["details"]; got.Type != "array" || got.Items["type"] != "object" {
+		t.Errorf("details = %+v, want an array of objects", got)
+	}
+	if got := jsonSchema(timestampType)["format"]; got != "date-time" {
+		t.Errorf("timestamp format = %v, want date-time", got)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// outputFormats are the accepted values of --output: lines for legacy SIEMs, or SARIF for code scanning
+var outputFormats = []string{"cef", "leef", "sarif"}
+
+// siemSeverity maps alert severities to the 0-10 scale shared by CEF and LEEF
+var siemSeverity = map[string]int{
+	severityMedium:   5,
+	severityHigh:     8,
+	severityCritical: 10,
+}
+
+// siemNotifier writes each alert as a single CEF or LEEF line, with times in epoch milliseconds
+type siemNotifier struct {
+	w       io.Writer
+	format  string
+	vendor  string
+	product string
+	version string
+}
+
+// siemEvent is the subset of an alert common to both formats
+type siemEvent struct {
+	id       string
+	name     string
+	severity int
+	fields   []alertDetail
+}
+
+func newSIEMEvent(al *alert) siemEvent {
+	ev := siemEvent{id: "alerter", name: al.Kind, severity: siemSeverity[al.Severity]}
+	if ev.name == "" {
+		ev.name = al.Message
+	}
+	a := al.Entry
+	if a == nil {
+		ev.fields = []alertDetail{{Name: "msg", Value: al.String()}}
+		return ev
+	}
+
+	ev.id = a.GetAction()
+	if al.Kind == "" {
+		ev.name = a.GetAction()
+	}
+	for _, f := range []alertDetail{
+		{Name: "rt", Value: strconv.FormatInt(a.GetTimestamp().UnixMilli(), 10)},
+		{Name: "suser", Value: actorName(a)},
+		{Name: "duser", Value: a.GetUser()},
+		{Name: "act", Value: a.GetAction()},
+		{Name: "src", Value: a.GetActorIP()},
+		{Name: "cs1", Value: auditLocation(a)},
+		{Name: "cs2", Value: a.GetOrg()},
+		{Name: "externalId", Value: a.GetDocumentID()},
+		{Name: "msg", Value: al.String()},
+	} {
+		if f.Value != "" {
+			ev.fields = append(ev.fields, f)
+		}
+	}
+	return ev
+}
+
+// leefKeys renames CEF keys to their LEEF equivalents; keys not listed are kept
+var leefKeys = map[string]string{
+	"rt":    "devTime",
+	"suser": "usrName",
+	"duser": "dstUsrName",
+	"cs1":   "repo",
+	"cs2":   "org",
+}
+
+// cefHeader escapes a CEF or LEEF header value
+var cefHeader = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", " ", "\r", " ")
+
+// cefValue escapes a CEF extension value
+var cefValue = strings.NewReplacer(`\`, `\\`, "=", `\=`, "\n", `\n`, "\r", `\r`)
+
+// leefValue escapes a LEEF attribute value, which is tab delimited
+var leefValue = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+// formatCEF renders an alert in the Common Event Format
+func (n siemNotifier) formatCEF(al *alert) string {
+	ev := newSIEMEvent(al)
+	ext := []string{}
+	for _, f := range ev.fields {
+		switch f.Name {
+		case "cs1":
+			ext = append(ext, "cs1Label=repo")
+		case "cs2":
+			ext = append(ext, "cs2Label=org")
+		}
+		ext = append(ext, f.Name+"="+cefValue.Replace(f.Value))
+	}
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		cefHeader.Replace(n.vendor), cefHeader.Replace(n.product), cefHeader.Replace(n.version),
+		cefHeader.Replace(ev.id), cefHeader.Replace(ev.name), ev.severity, strings.Join(ext, " "))
+}
+
+// formatLEEF renders an alert in the Log Event Extended Format, version 1.0
+func (n siemNotifier) formatLEEF(al *alert) string {
+	ev := newSIEMEvent(al)
+	attrs := []string{"cat=" + leefValue.Replace(ev.name), "sev=" + strconv.Itoa(ev.severity)}
+	for _, f := range ev.fields {
+		key := f.Name
+		if k, ok := leefKeys[key]; ok {
+			key = k
+		}
+		attrs = append(attrs, key+"="+leefValue.Replace(f.Value))
+	}
+	return fmt.Sprintf("LEEF:1.0|%s|%s|%s|%s|%s",
+		cefHeader.Replace(n.vendor), cefHeader.Replace(n.product), cefHeader.Replace(n.version),
+		cefHeader.Replace(ev.id), strings.Join(attrs, "\t"))
+}
+
+func (siemNotifier) local() {}
+
+func (n siemNotifier) Notify(_ context.Context, al *alert) error {
+	line := n.formatCEF(al)
+	if n.format == "leef" {
+		line = n.formatLEEF(al)
+	}
+	_, err := fmt.Fprintln(n.w, line)
+	return err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// slackAPIClient bounds Slack Web API calls, so that an unresponsive Slack cannot hang a run
+var slackAPIClient = &http.Client{Timeout: 30 * time.Second}
+
+// slackThread is the parent message that later alerts about an actor are posted under
+type slackThread struct {
+	TS     string    `json:"ts"`
+	Posted time.Time `json:"posted"`
+}
+
+// slackThreader posts alerts with the Slack Web API, replying in a thread to the first alert
+// about the same actor within window. Alerts without an entry are always posted as new messages.
+type slackThreader struct {
+	api     *slack.Client
+	channel string
+	path    string
+	window  time.Duration
+
+	// threads are keyed by actor
+	threads map[string]slackThread
+}
+
+func loadSlackThreader(token string, channel string, path string, window time.Duration) (*slackThreader, error) {
+	st := &slackThreader{api: slack.New(token, slack.OptionHTTPClient(slackAPIClient)), channel: channel, path: path, window: window, threads: map[string]slackThread{}}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(b, &st.threads); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	// Forget threads that can no longer be replied to, so the file does not grow forever
+	for actor, t := range st.threads {
+		if time.Since(t.Posted) >= window {
+			delete(st.threads, actor)
+		}
+	}
+	return st, nil
+}
+
+// threadGone reports whether a reply failed because its parent message no longer exists
+func threadGone(err error) bool {
+	var se slack.SlackErrorResponse
+	return errors.As(err, &se) && (se.Err == "thread_not_found" || se.Err == "message_not_found" || se.Err == "invalid_thread_ts")
+}
+
+func (st *slackThreader) Notify(ctx context.Context, al *alert) error {
+	text := slackText(al)
+	if al.Entry == nil {
+		log.Printf("[slack post] %s", text)
+		_, _, err := st.api.PostMessageContext(ctx, st.channel, slack.MsgOptionText(text, false))
+		return err
+	}
+
+	actor := actorName(al.Entry)
+	if t, ok := st.threads[actor]; ok && time.Since(t.Posted) < st.window {
+		log.Printf("[slack reply %s] %s", t.TS, text)
+		_, _, err := st.api.PostMessageContext(ctx, st.channel, slack.MsgOptionText(text, false), slack.MsgOptionTS(t.TS))
+		if !threadGone(err) {
+			return err
+		}
+		log.Printf("thread for %s is gone, starting a new one: %v", actor, err)
+	}
+
+	log.Printf("[slack post] %s", text)
+	_, ts, err := st.api.PostMessageContext(ctx, st.channel, slack.MsgOptionText(text, false))
+	if err != nil {
+		return err
+	}
+	st.threads[actor] = slackThread{TS: ts, Posted: time.Now()}
+	return nil
+}
+
+func (st *slackThreader) save() error {
+	b, err := json.Marshal(st.threads)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(st.path, b)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// stateFile returns the path of a file in --state-dir, which keeps what a run needs to know about earlier runs
+func stateFile(name string) string {
+	return filepath.Join(*stateDirFlag, name)
+}
+
+// writeFileAtomic writes b to path through a temporary file in the same directory, so that an interrupted or
+// concurrent run never sees a partial file
+func writeFileAtomic(path string, b []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	path := filepath.Join(dir, "throttle")
+
+	for _, want := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(want)); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s = %q, want %q", path, b, want)
+		}
+	}
+
+	// No temporary files are left behind
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%s has %d files, want 1", dir, len(files))
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("%s mode = %v, want 0600", path, fi.Mode().Perm())
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	defer func(dir string) { *stateDirFlag = dir }(*stateDirFlag)
+	*stateDirFlag = "/var/lib/github-audit-alerter"
+	if got, want := stateFile("throttle"), "/var/lib/github-audit-alerter/throttle"; got != want {
+		t.Errorf("stateFile = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// metrics, if set, receives the run's StatsD metrics
+var metrics *statsd
+
+// statsd sends counters and timers to a StatsD server over UDP, with DogStatsD tags. Sends never
+// block on the server, and failures are only logged once, so metrics cannot slow or fail a run.
+type statsd struct {
+	conn   net.Conn
+	prefix string
+	// tags are added to every metric, as "key:value"
+	tags   []string
+	warned bool
+}
+
+// newStatsd dials addr, which only resolves it as UDP is connectionless
+func newStatsd(addr string, prefix string, tags []string) (*statsd, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsd{conn: conn, prefix: prefix, tags: tags}, nil
+}
+
+// parseStatsdTags parses comma separated "key:value" tags
+func parseStatsdTags(list string) ([]string, error) {
+	tags := []string{}
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if k, v, ok := strings.Cut(t, ":"); !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("%q is not in key:value form", t)
+		}
+		tags = append(tags, t)
+	}
+	return tags, nil
+}
+
+func (s *statsd) send(name string, value string, kind string, tags []string) {
+	if s == nil {
+		return
+	}
+	msg := fmt.Sprintf("%s%s:%s|%s", s.prefix, name, value, kind)
+	if all := append(append([]string{}, s.tags...), tags...); len(all) > 0 {
+		msg += "|#" + strings.Join(all, ",")
+	}
+	if _, err := s.conn.Write([]byte(msg)); err != nil && !s.warned {
+		s.warned = true
+		log.Printf("statsd: %v", err)
+	}
+}
+
+// count adds n to a counter
+func (s *statsd) count(name string, n int, tags ...string) {
+	s.send(name, fmt.Sprint(n), "c", tags)
+}
+
+// timing records a duration in milliseconds
+func (s *statsd) timing(name string, d time.Duration, tags ...string) {
+	s.send(name, fmt.Sprint(d.Milliseconds()), "ms", tags)
+}
+
+func (s *statsd) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// metricDetector names the detector that raised an alert, for tagging metrics: the alert's kind without
+// its threshold, "web" for other audit entries, or "alerter" for alerts about the alerter itself
+func metricDetector(al *alert) string {
+	switch {
+	case al.Kind != "":
+		name, _, _ := strings.Cut(al.Kind, "[")
+		return strings.ReplaceAll(name, " ", "_")
+	case al.Entry != nil:
+		return "web"
+	default:
+		return "alerter"
+	}
+}
+
+// statsdTransport times GitHub API requests
+type statsdTransport struct {
+	base http.RoundTripper
+}
+
+func (t statsdTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	status := "error"
+	if resp != nil {
+		status = fmt.Sprint(resp.StatusCode)
+	}
+	metrics.timing("github.api.latency", time.Since(start), "status:"+status)
+	return resp, err
+}
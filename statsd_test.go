@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statsdServer listens for StatsD packets, returning a function that reads the next n
+func statsdServer(t *testing.T) (addr string, read func(n int) []string) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	return pc.LocalAddr().String(), func(n int) []string {
+		got := []string{}
+		buf := make([]byte, 1024)
+		for len(got) < n {
+			pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+			l, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				t.Fatalf("read %d of %d packets: %v", len(got), n, err)
+			}
+			got = append(got, string(buf[:l]))
+		}
+		return got
+	}
+}
+
+func TestStatsd(t *testing.T) {
+	addr, read := statsdServer(t)
+	s, err := newStatsd(addr, "gaa.", []string{"org:" + testOrg, "env:prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.count("alerts", 2, "detector:web")
+	s.timing("run.duration", 1500*time.Millisecond)
+	want := []string{
+		"gaa.alerts:2|c|#org:acme,env:prod,detector:web",
+		"gaa.run.duration:1500|ms|#org:acme,env:prod",
+	}
+	if got := read(len(want)); !equalStrings(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	// Without --statsd-addr, metrics is nil and sends nothing
+	var none *statsd
+	none.count("alerts", 1)
+	none.timing("run.duration", time.Second)
+	if err := none.Close(); err != nil {
+		t.Errorf("Close of nil statsd = %v", err)
+	}
+}
+
+func TestParseStatsdTags(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{}, false},
+		{"env:prod", []string{"env:prod"}, false},
+		{" env:prod , team:security ,", []string{"env:prod", "team:security"}, false},
+		{"url:https://example.com", []string{"url:https://example.com"}, false},
+		{"env", nil, true},
+		{"env:", nil, true},
+		{":prod", nil, true},
+	} {
+		got, err := parseStatsdTags(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseStatsdTags(%q) error = %v, want error %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if !tc.wantErr && !equalStrings(got, tc.want) {
+			t.Errorf("parseStatsdTags(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestMetricDetector(t *testing.T) {
+	a := entry("repo.destroy", "alice", "widgets", ago(time.Minute))
+	for _, tc := range []struct {
+		al   *alert
+		want string
+	}{
+		{newAlert(a, "", "alice destroyed widgets", repoSet{}), "web"},
+		{newAlert(a, "excessive clone[>=3]", "alice cloned 3 repos", repoSet{}), "excessive_clone"},
+		{newAlert(a, "grant burst", "mallory was granted 3 repos", repoSet{}), "grant_burst"},
+		{newAlert(nil, "", "alerter error", repoSet{}), "alerter"},
+	} {
+		if got := metricDetector(tc.al); got != tc.want {
+			t.Errorf("metricDetector(%q) = %q, want %q", tc.al, got, tc.want)
+		}
+	}
+}
+
+func TestStatsdTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	addr, read := statsdServer(t)
+	var err error
+	if metrics, err = newStatsd(addr, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		metrics.Close()
+		metrics = nil
+	}()
+
+	c := &http.Client{Transport: statsdTransport{base: http.DefaultTransport}}
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	got := read(1)[0]
+	if !strings.HasPrefix(got, "github.api.latency:") || !strings.HasSuffix(got, "|ms|#status:404") {
+		t.Errorf("sent %q, want a github.api.latency timer tagged status:404", got)
+	}
+}
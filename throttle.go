@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+)
+
+// throttleState is the on-disk form of a throttle, so that the cap spans runs
+type throttleState struct {
+	Start      time.Time `json:"start"`
+	Sent       int       `json:"sent"`
+	Suppressed int       `json:"suppressed"`
+}
+
+// throttle caps the number of alerts sent per window; critical alerts are exempt
+type throttle struct {
+	path   string
+	max    int
+	window time.Duration
+
+	state throttleState
+}
+
+// loadThrottle reads the throttle state from path, starting a new window if the last one has ended.
+// If the last window suppressed alerts, a summary of them is returned.
+func loadThrottle(path string, max int, window time.Duration) (*throttle, string, error) {
+	t := &throttle{path: path, max: max, window: window}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, "", err
+	default:
+		if err := json.Unmarshal(b, &t.state); err != nil {
+			return nil, "", fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	now := time.Now()
+	if now.Sub(t.state.Start) < window {
+		return t, "", nil
+	}
+
+	summary := ""
+	if t.state.Suppressed > 0 {
+		summary = fmt.Sprintf("%d additional alerts were suppressed between %s and %s, after %d alerts",
+			t.state.Suppressed, t.state.Start.Format(time.RFC3339), t.state.Start.Add(window).Format(time.RFC3339), max)
+	}
+	t.state = throttleState{Start: now}
+	return t, summary, nil
+}
+
+// allow reports whether an alert may be sent, counting it towards the cap
+func (t *throttle) allow(al *alert) bool {
+	if t == nil || al.Severity == severityCritical {
+		return true
+	}
+
+	if t.state.Sent >= t.max {
+		t.state.Suppressed++
+		log.Printf("throttled, %d alerts already sent since %s: %s", t.state.Sent, t.state.Start, al)
+		return false
+	}
+	t.state.Sent++
+	return true
+}
+
+func (t *throttle) save() error {
+	b, err := json.Marshal(t.state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(t.path, b)
+}
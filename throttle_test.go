@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeThrottleState writes a throttle state file, as left by an earlier run
+func writeThrottleState(t *testing.T, path string, state throttleState) {
+	t.Helper()
+	b, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThrottleWindow(t *testing.T) {
+	const window = time.Hour
+	start := time.Now().Add(-window).Truncate(time.Second)
+
+	for _, tc := range []struct {
+		name    string
+		state   *throttleState
+		allowed int
+		summary string
+	}{
+		{
+			name:    "first run",
+			allowed: 2,
+		},
+		{
+			name:    "window still open",
+			state:   &throttleState{Start: start.Add(time.Minute), Sent: 1, Suppressed: 3},
+			allowed: 1,
+		},
+		{
+			name:    "window ended with alerts suppressed",
+			state:   &throttleState{Start: start, Sent: 2, Suppressed: 3},
+			allowed: 2,
+			summary: fmt.Sprintf("3 additional alerts were suppressed between %s and %s, after 2 alerts",
+				start.Format(time.RFC3339), start.Add(window).Format(time.RFC3339)),
+		},
+		{
+			name:    "window ended with nothing suppressed",
+			state:   &throttleState{Start: start, Sent: 2},
+			allowed: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "throttle")
+			if tc.state != nil {
+				writeThrottleState(t, path, *tc.state)
+			}
+
+			th, summary, err := loadThrottle(path, 2, window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary != tc.summary {
+				t.Errorf("summary = %q, want %q", summary, tc.summary)
+			}
+			allowed := 0
+			for i := range 5 {
+				if th.allow(newAlert(nil, "", fmt.Sprintf("alert %d", i), repoSet{})) {
+					allowed++
+				}
+			}
+			if allowed != tc.allowed {
+				t.Errorf("allowed %d alerts, want %d", allowed, tc.allowed)
+			}
+		})
+	}
+}
+
+func TestThrottleSummaryNextRun(t *testing.T) {
+	const window = time.Hour
+	path := filepath.Join(t.TempDir(), "throttle")
+
+	th, summary, err := loadThrottle(path, 2, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "" {
+		t.Errorf("first run summary = %q, want none", summary)
+	}
+	for i := range 4 {
+		th.allow(newAlert(nil, "", fmt.Sprintf("alert %d", i), repoSet{}))
+	}
+	critical := newAlert(nil, "", "critical", repoSet{})
+	critical.Severity = severityCritical
+	if !th.allow(critical) {
+		t.Error("critical alert was throttled")
+	}
+	if err := th.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A run within the window carries on counting, and has nothing to summarize yet
+	th, summary, err = loadThrottle(path, 2, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "" {
+		t.Errorf("summary within the window = %q, want none", summary)
+	}
+	if th.allow(newAlert(nil, "", "alert 4", repoSet{})) {
+		t.Error("alert allowed past the cap within the window")
+	}
+	if err := th.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the window has ended, the next run reports the alerts suppressed in it
+	start := time.Now().Add(-window).Truncate(time.Second)
+	writeThrottleState(t, path, throttleState{Start: start, Sent: th.state.Sent, Suppressed: th.state.Suppressed})
+	th, summary, err = loadThrottle(path, 2, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("3 additional alerts were suppressed between %s and %s, after 2 alerts",
+		start.Format(time.RFC3339), start.Add(window).Format(time.RFC3339))
+	if summary != want {
+		t.Errorf("summary = %q, want %q", summary, want)
+	}
+	if th.state.Sent != 0 || th.state.Suppressed != 0 {
+		t.Errorf("new window state = %+v, want no alerts counted", th.state)
+	}
+}
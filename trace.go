@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+)
+
+// alertTrace records why an alert fired, attached to its JSON with --include-trace
+type alertTrace struct {
+	// Detector is what found the entry: "web", "phrase", or the alert's kind
+	Detector string `json:"detector"`
+	// Override is the prefix of the opt-in detector that surfaced the entry, if any
+	Override string `json:"override,omitempty"`
+	// AlwaysAlert is set for actions that bypass every filter
+	AlwaysAlert bool `json:"always_alert,omitempty"`
+	// Passed lists the enabled filters the entry was checked against, in order
+	Passed []string `json:"passed"`
+	// Ignores lists the ignore patterns matching the action, as "list: pattern". They were bypassed by
+	// Override or CriticalRepo; no other pattern matched.
+	Ignores      []string `json:"ignores,omitempty"`
+	CriticalRepo bool     `json:"critical_repo"`
+	// Severity lists each decision that set the alert's severity, starting with its base severity
+	Severity []string `json:"severity"`
+}
+
+// newTrace traces an alert about an entry as found, before its severity is adjusted
+func newTrace(al *alert, s Settings, phrase bool) *alertTrace {
+	a := al.Entry
+	t := &alertTrace{
+		Detector:     al.Kind,
+		Passed:       []string{},
+		CriticalRepo: s.CriticalRepos.has(auditLocation(a)),
+		AlwaysAlert:  alwaysAlert(a),
+	}
+	if o := matchOverride(a, s.Overrides); o != nil {
+		t.Override = o.prefix
+	}
+
+	switch {
+	case phrase:
+		t.Detector = "phrase"
+	case t.AlwaysAlert:
+		t.Detector = "web"
+	case al.Kind == "":
+		t.Detector = "web"
+		if s.MaxAge > 0 {
+			t.Passed = append(t.Passed, "max-age")
+		}
+		if s.RepoFilter != nil {
+			t.Passed = append(t.Passed, "repo-filter")
+		}
+		t.Passed = append(t.Passed, "ignore-lists")
+		if s.NewRepoGrace > 0 {
+			t.Passed = append(t.Passed, "new-repo-grace")
+		}
+		t.Passed = append(t.Passed, commonFilters(s)...)
+		if s.LearnedActions != nil {
+			t.Passed = append(t.Passed, "learned-actions")
+		}
+	case a.GetAction() == "git.clone":
+		if s.MaxAge > 0 {
+			t.Passed = append(t.Passed, "max-age")
+		}
+		if !s.IncludePublicClones {
+			t.Passed = append(t.Passed, "private-repo")
+		}
+		t.Passed = append(t.Passed, commonFilters(s)...)
+		t.Passed = append(t.Passed, "threshold")
+	default:
+		if s.MaxAge > 0 {
+			t.Passed = append(t.Passed, "max-age")
+		}
+		t.Passed = append(t.Passed, commonFilters(s)...)
+		t.Passed = append(t.Passed, "threshold")
+	}
+
+	for _, p := range s.GlobalIgnoreActions {
+		if actionRegexp([]string{p}).MatchString(a.GetAction()) {
+			t.Ignores = append(t.Ignores, "universal: "+p)
+		}
+	}
+	for _, p := range s.NonCriticalIgnoreActions {
+		if actionRegexp([]string{p}).MatchString(a.GetAction()) {
+			t.Ignores = append(t.Ignores, "non-critical: "+p)
+		}
+	}
+
+	base := "default"
+	switch {
+	case al.Severity != alertSeverity(a, s.CriticalRepos):
+		base = "detector"
+	case t.CriticalRepo:
+		base = "critical repo"
+	case t.AlwaysAlert:
+		base = "always-alert action"
+	case a.GetAction() == "git.clone":
+		base = "clone"
+	}
+	t.Severity = []string{fmt.Sprintf("%s: %s", al.Severity, base)}
+	return t
+}
+
+// commonFilters returns the enabled filters that every detector but phrase searches applies last
+func commonFilters(s Settings) []string {
+	passed := []string{}
+	if !s.IncludeBots {
+		passed = append(passed, "bots")
+	}
+	if len(s.IgnoreCIDRs) > 0 {
+		passed = append(passed, "trusted-ips")
+	}
+	return passed
+}
+
+// raised records a severity decision, if it changed the severity from prev
+func (t *alertTrace) raised(prev string, sev string, why string) {
+	if t == nil || prev == sev {
+		return
+	}
+	t.Severity = append(t.Severity, fmt.Sprintf("%s: %s", sev, why))
+}
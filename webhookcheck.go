@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxWebhookResponseBytes bounds how much of a webhook response is read to validate it
+const maxWebhookResponseBytes = 1 << 20
+
+// webhookClient posts to Slack and Google Chat webhooks, validating responses with --webhook-success if set.
+// It times out so that an unresponsive webhook fails its delivery rather than hanging the run.
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// responseCheck validates a successful webhook response body, for gateways that report failures with a 200
+type responseCheck struct {
+	// re, if set, must match the body
+	re *regexp.Regexp
+	// path, if set, is the dotted path of a JSON field that must be present, and equal want if it is set
+	path []string
+	want *string
+}
+
+// parseResponseCheck parses a regexp, or "json:" followed by a dotted field path and optionally "=value"
+func parseResponseCheck(spec string) (*responseCheck, error) {
+	p, ok := strings.CutPrefix(spec, "json:")
+	if !ok {
+		re, err := regexp.Compile(spec)
+		if err != nil {
+			return nil, err
+		}
+		return &responseCheck{re: re}, nil
+	}
+
+	rc := &responseCheck{}
+	if field, want, ok := strings.Cut(p, "="); ok {
+		p = field
+		rc.want = &want
+	}
+	if p == "" {
+		return nil, fmt.Errorf("%q has no JSON field path", spec)
+	}
+	rc.path = strings.Split(p, ".")
+	return rc, nil
+}
+
+// check returns an error if body does not show success
+func (rc *responseCheck) check(body []byte) error {
+	if rc.re != nil {
+		if !rc.re.Match(body) {
+			return fmt.Errorf("response does not match %q: %s", rc.re, truncate(string(body), 1024))
+		}
+		return nil
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("response is not JSON: %s", truncate(string(body), 1024))
+	}
+	for _, k := range rc.path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			v = nil
+			break
+		}
+		v = m[k]
+	}
+	path := strings.Join(rc.path, ".")
+	switch {
+	case v == nil:
+		return fmt.Errorf("response has no %s: %s", path, truncate(string(body), 1024))
+	case rc.want != nil && fmt.Sprint(v) != *rc.want:
+		return fmt.Errorf("response has %s=%v, not %s: %s", path, v, *rc.want, truncate(string(body), 1024))
+	}
+	return nil
+}
+
+// checkingTransport fails requests whose successful responses do not pass a responseCheck.
+// Unsuccessful responses are left to the caller, which already treats them as failures.
+type checkingTransport struct {
+	rt    http.RoundTripper
+	check *responseCheck
+}
+
+func (t checkingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseBytes))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.check.check(body); err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}